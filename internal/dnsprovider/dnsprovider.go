@@ -2,6 +2,7 @@ package dnsprovider
 
 import (
 	"fmt"
+	"os"
 	"regexp"
 	"strings"
 
@@ -44,8 +45,8 @@ func Init(config configuration.Config) (provider.Provider, error) {
 	}
 	log.Info(createMsg)
 
-	mikrotikConfig := mikrotik.MikrotikConnectionConfig{}
-	if err := env.Parse(&mikrotikConfig); err != nil {
+	mikrotikConfigs, err := mikrotik.ReadConnectionConfigs(os.Environ())
+	if err != nil {
 		return nil, fmt.Errorf("reading mikrotik configuration failed: %v", err)
 	}
 
@@ -53,6 +54,15 @@ func Init(config configuration.Config) (provider.Provider, error) {
 	if err := env.Parse(&mikrotikDefaults); err != nil {
 		return nil, fmt.Errorf("reading mikrotik defaults failed: %v", err)
 	}
+	mikrotikDefaults.Domains, err = mikrotik.ReadDomainDefaults(os.Environ())
+	if err != nil {
+		return nil, fmt.Errorf("reading mikrotik domain defaults failed: %v", err)
+	}
+
+	providerConfig := mikrotik.MikrotikProviderConfig{}
+	if err := env.Parse(&providerConfig); err != nil {
+		return nil, fmt.Errorf("reading mikrotik provider configuration failed: %v", err)
+	}
 
-	return mikrotik.NewMikrotikProvider(domainFilter, &mikrotikDefaults, &mikrotikConfig)
+	return mikrotik.NewMikrotikProvider(domainFilter, &mikrotikDefaults, mikrotikConfigs, &providerConfig)
 }
@@ -0,0 +1,455 @@
+// API Docs: https://help.mikrotik.com/docs/display/ROS/API
+
+package mikrotik
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultAPIPort    = "8728"
+	defaultAPITLSPort = "8729"
+)
+
+// apiError is returned when the router replies to an API command with a trap
+type apiError struct {
+	Category string
+	Message  string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("request failed: %s", e.Message)
+}
+
+// Unwrap returns the sentinel error matching the message of the trap, if it is a known one
+func (e *apiError) Unwrap() error {
+	return routerOSError(e.Message)
+}
+
+// apiTransport talks to a router through the binary API service, over plain TCP or TLS.
+// A single connection is kept open and commands are sent over it one at a time.
+type apiTransport struct {
+	address        string
+	tlsConfig      *tls.Config
+	username       string
+	password       string
+	connectTimeout time.Duration
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// newAPITransport creates a transport for the binary API at the given URL.
+// The api:// scheme connects over plain TCP (port 8728 by default), apis:// over TLS (port 8729 by default).
+func newAPITransport(baseUrl string, config *MikrotikConnectionConfig) (*apiTransport, error) {
+	u, err := url.Parse(baseUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API URL %s: %w", baseUrl, err)
+	}
+
+	t := &apiTransport{
+		username:       config.Username,
+		password:       config.Password,
+		connectTimeout: config.ConnectTimeout,
+	}
+
+	port := u.Port()
+	switch u.Scheme {
+	case "api":
+		if port == "" {
+			port = defaultAPIPort
+		}
+	case "apis":
+		if port == "" {
+			port = defaultAPITLSPort
+		}
+		t.tlsConfig = &tls.Config{InsecureSkipVerify: config.SkipTLSVerify}
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q in API URL %s, expected api:// or apis://", u.Scheme, baseUrl)
+	}
+	t.address = net.JoinHostPort(u.Hostname(), port)
+
+	return t, nil
+}
+
+func (t *apiTransport) get(ctx context.Context, path string, query url.Values, out any) error {
+	items, _, err := t.run(ctx, fmt.Sprintf("/%s/print", path), queryWords(query)...)
+	if err != nil {
+		return err
+	}
+
+	// Menus like system/resource hold a single item, which is decoded on its own
+	var data []byte
+	if reflect.TypeOf(out).Elem().Kind() == reflect.Slice {
+		data, err = json.Marshal(items)
+	} else if len(items) > 0 {
+		data, err = json.Marshal(items[0])
+	} else {
+		return fmt.Errorf("no item returned for %s", path)
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, out)
+}
+
+func (t *apiTransport) add(ctx context.Context, path string, item any, out any) error {
+	attributes, err := attributeWords(item)
+	if err != nil {
+		log.Errorf("error marshalling item: %v", err)
+		return err
+	}
+
+	_, done, err := t.run(ctx, fmt.Sprintf("/%s/add", path), attributes...)
+	if err != nil {
+		return err
+	}
+
+	// The API only replies with the ID of the new item, so it is fetched to return it like the REST API does
+	return t.get(ctx, path, url.Values{".id": {done["ret"]}}, out)
+}
+
+func (t *apiTransport) set(ctx context.Context, path, id string, fields any, out any) error {
+	attributes, err := attributeWords(fields)
+	if err != nil {
+		log.Errorf("error marshalling fields: %v", err)
+		return err
+	}
+
+	args := append([]string{fmt.Sprintf("=.id=%s", id)}, attributes...)
+	if _, _, err := t.run(ctx, fmt.Sprintf("/%s/set", path), args...); err != nil {
+		return err
+	}
+
+	return t.get(ctx, path, url.Values{".id": {id}}, out)
+}
+
+func (t *apiTransport) remove(ctx context.Context, path, id string) error {
+	_, _, err := t.run(ctx, fmt.Sprintf("/%s/remove", path), fmt.Sprintf("=.id=%s", id))
+	return err
+}
+
+// run sends a command to the router, connecting and logging in first if needed.
+// It returns the attributes of all replied items, along with the attributes of the final !done reply.
+func (t *apiTransport) run(ctx context.Context, command string, args ...string) ([]map[string]string, map[string]string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.conn == nil {
+		if err := t.connect(ctx); err != nil {
+			log.Errorf("error connecting to %s: %v", t.address, err)
+			return nil, nil, err
+		}
+	}
+
+	log.Debugf("sending command to %s: %s %v", t.address, command, args)
+	items, done, err := t.exchange(ctx, append([]string{command}, args...))
+	if err != nil {
+		// A trap leaves the connection usable, anything else leaves it in an unknown state
+		var apiErr *apiError
+		if !errors.As(err, &apiErr) {
+			t.close()
+		}
+		log.Errorf("command %s failed: %v", command, err)
+		return nil, nil, err
+	}
+
+	return items, done, nil
+}
+
+// connect opens the connection to the router and logs in
+func (t *apiTransport) connect(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: t.connectTimeout}
+
+	var conn net.Conn
+	var err error
+	if t.tlsConfig != nil {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: t.tlsConfig}).DialContext(ctx, "tcp", t.address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", t.address)
+	}
+	if err != nil {
+		return err
+	}
+	t.conn = conn
+	t.reader = bufio.NewReader(conn)
+
+	if err := t.login(ctx); err != nil {
+		t.close()
+		// A trap is the router rejecting the credentials, anything else is the connection failing
+		var apiErr *apiError
+		if errors.As(err, &apiErr) {
+			return fmt.Errorf("login failed: %w: %w", ErrUnauthorized, err)
+		}
+		return fmt.Errorf("login failed: %w", err)
+	}
+
+	return nil
+}
+
+// login authenticates the connection. RouterOS versions before 6.43 reply with a challenge instead of logging in
+// directly, in which case the MD5 challenge-response login is used.
+func (t *apiTransport) login(ctx context.Context) error {
+	_, done, err := t.exchange(ctx, []string{"/login", "=name=" + t.username, "=password=" + t.password})
+	if err != nil {
+		return err
+	}
+
+	challenge, ok := done["ret"]
+	if !ok {
+		return nil
+	}
+
+	challengeBytes, err := hex.DecodeString(challenge)
+	if err != nil {
+		return fmt.Errorf("invalid login challenge: %w", err)
+	}
+	hash := md5.New()
+	hash.Write([]byte{0})
+	hash.Write([]byte(t.password))
+	hash.Write(challengeBytes)
+
+	_, _, err = t.exchange(ctx, []string{"/login", "=name=" + t.username, "=response=00" + hex.EncodeToString(hash.Sum(nil))})
+	return err
+}
+
+// close closes the connection, so that the next command opens a new one
+func (t *apiTransport) close() {
+	if t.conn != nil {
+		t.conn.Close()
+	}
+	t.conn = nil
+	t.reader = nil
+}
+
+// exchange writes a sentence and reads the replies until the command is done.
+// The exchange is bounded by the deadline of ctx, and aborted as soon as ctx is done.
+func (t *apiTransport) exchange(ctx context.Context, words []string) ([]map[string]string, map[string]string, error) {
+	conn := t.conn
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, nil, err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	items, done, err := t.readReplies(words)
+	if err != nil && ctx.Err() != nil {
+		return nil, nil, fmt.Errorf("command aborted: %w", ctx.Err())
+	}
+	return items, done, err
+}
+
+// readReplies writes a sentence and reads the replies until the command is done
+func (t *apiTransport) readReplies(words []string) ([]map[string]string, map[string]string, error) {
+	if err := writeSentence(t.conn, words); err != nil {
+		return nil, nil, err
+	}
+
+	var items []map[string]string
+	var trap error
+	for {
+		reply, err := readSentence(t.reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(reply) == 0 {
+			continue
+		}
+
+		attributes := sentenceAttributes(reply[1:])
+		switch reply[0] {
+		case "!re":
+			items = append(items, attributes)
+		case "!trap":
+			// The router still ends the command with !done after a trap
+			if trap == nil {
+				trap = &apiError{Category: attributes["category"], Message: attributes["message"]}
+			}
+		case "!fatal":
+			return nil, nil, fmt.Errorf("router closed the connection: %s", strings.Join(reply[1:], " "))
+		case "!done":
+			if trap != nil {
+				return nil, nil, trap
+			}
+			return items, attributes, nil
+		case "!empty":
+		default:
+			log.Debugf("ignoring unexpected reply: %v", reply)
+		}
+	}
+}
+
+// queryWords converts a query to API query words. The values of a parameter are matched as any of them, while
+// all parameters have to match.
+func queryWords(query url.Values) []string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var words []string
+	for _, key := range keys {
+		values := query[key]
+		for i, value := range values {
+			words = append(words, fmt.Sprintf("?%s=%s", key, value))
+			if i > 0 {
+				words = append(words, "?#|")
+			}
+		}
+		// A is the default type, so A records may be returned without a type
+		if key == "type" && slices.Contains(values, "A") {
+			words = append(words, "?-type", "?#|")
+		}
+	}
+	for i := 1; i < len(keys); i++ {
+		words = append(words, "?#&")
+	}
+
+	return words
+}
+
+// attributeWords converts an item to API attribute words, using the same names as its JSON representation
+func attributeWords(item any) ([]string, error) {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+
+	var attributes map[string]any
+	if err := json.Unmarshal(data, &attributes); err != nil {
+		return nil, err
+	}
+
+	words := make([]string, 0, len(attributes))
+	for key, value := range attributes {
+		words = append(words, fmt.Sprintf("=%s=%v", key, value))
+	}
+	sort.Strings(words)
+
+	return words, nil
+}
+
+// sentenceAttributes parses the =key=value words of a reply
+func sentenceAttributes(words []string) map[string]string {
+	attributes := map[string]string{}
+	for _, word := range words {
+		if !strings.HasPrefix(word, "=") {
+			continue
+		}
+		key, value, _ := strings.Cut(word[1:], "=")
+		attributes[key] = value
+	}
+	return attributes
+}
+
+// writeSentence writes the words of a sentence, followed by the empty word that terminates it
+func writeSentence(w io.Writer, words []string) error {
+	var buf []byte
+	for _, word := range words {
+		buf = append(buf, encodeLength(len(word))...)
+		buf = append(buf, word...)
+	}
+	buf = append(buf, 0)
+
+	_, err := w.Write(buf)
+	return err
+}
+
+// readSentence reads the words of a sentence up to the empty word that terminates it
+func readSentence(r *bufio.Reader) ([]string, error) {
+	var words []string
+	for {
+		length, err := readLength(r)
+		if err != nil {
+			return nil, err
+		}
+		if length == 0 {
+			return words, nil
+		}
+
+		word := make([]byte, length)
+		if _, err := io.ReadFull(r, word); err != nil {
+			return nil, err
+		}
+		words = append(words, string(word))
+	}
+}
+
+// encodeLength encodes the length of a word, which takes between 1 and 5 bytes depending on its value
+func encodeLength(length int) []byte {
+	l := uint32(length)
+	switch {
+	case l < 0x80:
+		return []byte{byte(l)}
+	case l < 0x4000:
+		l |= 0x8000
+		return []byte{byte(l >> 8), byte(l)}
+	case l < 0x200000:
+		l |= 0xC00000
+		return []byte{byte(l >> 16), byte(l >> 8), byte(l)}
+	case l < 0x10000000:
+		l |= 0xE0000000
+		return []byte{byte(l >> 24), byte(l >> 16), byte(l >> 8), byte(l)}
+	default:
+		return []byte{0xF0, byte(l >> 24), byte(l >> 16), byte(l >> 8), byte(l)}
+	}
+}
+
+// readLength reads the encoded length of a word
+func readLength(r *bufio.Reader) (int, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	var length uint32
+	var extra int
+	switch {
+	case first&0x80 == 0x00:
+		return int(first), nil
+	case first&0xC0 == 0x80:
+		length, extra = uint32(first&^0xC0), 1
+	case first&0xE0 == 0xC0:
+		length, extra = uint32(first&^0xE0), 2
+	case first&0xF0 == 0xE0:
+		length, extra = uint32(first&^0xF0), 3
+	case first == 0xF0:
+		length, extra = 0, 4
+	default:
+		return 0, fmt.Errorf("invalid word length prefix: %#x", first)
+	}
+
+	for i := 0; i < extra; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		length = length<<8 | uint32(b)
+	}
+
+	return int(length), nil
+}
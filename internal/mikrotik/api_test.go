@@ -0,0 +1,421 @@
+// api_test.go
+package mikrotik
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+func TestWordLength(t *testing.T) {
+	testCases := []struct {
+		length  int
+		encoded []byte
+	}{
+		{length: 0x00, encoded: []byte{0x00}},
+		{length: 0x7F, encoded: []byte{0x7F}},
+		{length: 0x80, encoded: []byte{0x80, 0x80}},
+		{length: 0x3FFF, encoded: []byte{0xBF, 0xFF}},
+		{length: 0x4000, encoded: []byte{0xC0, 0x40, 0x00}},
+		{length: 0x1FFFFF, encoded: []byte{0xDF, 0xFF, 0xFF}},
+		{length: 0x200000, encoded: []byte{0xE0, 0x20, 0x00, 0x00}},
+		{length: 0xFFFFFFF, encoded: []byte{0xEF, 0xFF, 0xFF, 0xFF}},
+		{length: 0x10000000, encoded: []byte{0xF0, 0x10, 0x00, 0x00, 0x00}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%#x", tc.length), func(t *testing.T) {
+			encoded := encodeLength(tc.length)
+			if !bytes.Equal(encoded, tc.encoded) {
+				t.Errorf("Expected encoding %x, got %x", tc.encoded, encoded)
+			}
+
+			length, err := readLength(bufio.NewReader(bytes.NewReader(encoded)))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if length != tc.length {
+				t.Errorf("Expected length %#x, got %#x", tc.length, length)
+			}
+		})
+	}
+}
+
+func TestSentence(t *testing.T) {
+	words := []string{"/ip/dns/static/print", "?name=" + strings.Repeat("a", 200), "=.proplist=.id"}
+
+	var buf bytes.Buffer
+	if err := writeSentence(&buf, words); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	read, err := readSentence(bufio.NewReader(&buf))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(read, words) {
+		t.Errorf("Expected words %v, got %v", words, read)
+	}
+}
+
+func TestQueryWords(t *testing.T) {
+	testCases := []struct {
+		name     string
+		query    map[string][]string
+		expected []string
+	}{
+		{
+			name:     "Single parameter",
+			query:    map[string][]string{"name": {"example.com"}},
+			expected: []string{"?name=example.com"},
+		},
+		{
+			name:     "Multiple parameters",
+			query:    map[string][]string{"name": {"example.com"}, "type": {"CNAME"}},
+			expected: []string{"?name=example.com", "?type=CNAME", "?#&"},
+		},
+		{
+			name:     "Multiple values",
+			query:    map[string][]string{"type": {"AAAA", "CNAME", "TXT"}},
+			expected: []string{"?type=AAAA", "?type=CNAME", "?#|", "?type=TXT", "?#|"},
+		},
+		{
+			name:     "A records without type",
+			query:    map[string][]string{"type": {"A", "AAAA"}},
+			expected: []string{"?type=A", "?type=AAAA", "?#|", "?-type", "?#|"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			words := queryWords(tc.query)
+			if !reflect.DeepEqual(words, tc.expected) {
+				t.Errorf("Expected words %v, got %v", tc.expected, words)
+			}
+		})
+	}
+}
+
+func TestNewAPITransport(t *testing.T) {
+	testCases := []struct {
+		name          string
+		baseUrl       string
+		address       string
+		tls           bool
+		expectedError bool
+	}{
+		{name: "Plain with default port", baseUrl: "api://192.168.88.1", address: "192.168.88.1:8728"},
+		{name: "TLS with default port", baseUrl: "apis://192.168.88.1", address: "192.168.88.1:8729", tls: true},
+		{name: "Custom port", baseUrl: "api://router.local:18728", address: "router.local:18728"},
+		{name: "REST URL", baseUrl: "https://192.168.88.1:443", expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			transport, err := newAPITransport(tc.baseUrl, &MikrotikConnectionConfig{})
+			if tc.expectedError {
+				if err == nil {
+					t.Fatalf("Expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if transport.address != tc.address {
+				t.Errorf("Expected address %s, got %s", tc.address, transport.address)
+			}
+			if (transport.tlsConfig != nil) != tc.tls {
+				t.Errorf("Expected TLS %v, got %v", tc.tls, transport.tlsConfig != nil)
+			}
+		})
+	}
+}
+
+// mockAPIRouter is a minimal binary API server that keeps static DNS records in memory
+type mockAPIRouter struct {
+	listener net.Listener
+	records  []map[string]string
+	nextID   int
+}
+
+func newMockAPIRouter(t *testing.T) *mockAPIRouter {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	router := &mockAPIRouter{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go router.serve(conn)
+		}
+	}()
+
+	return router
+}
+
+func (m *mockAPIRouter) url() string {
+	return "api://" + m.listener.Addr().String()
+}
+
+func (m *mockAPIRouter) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	loggedIn := false
+
+	for {
+		words, err := readSentence(reader)
+		if err != nil {
+			return
+		}
+		attributes := sentenceAttributes(words[1:])
+
+		reply := func(sentences ...[]string) {
+			for _, sentence := range sentences {
+				_ = writeSentence(conn, sentence)
+			}
+		}
+		trap := func(message string) {
+			reply([]string{"!trap", "=message=" + message}, []string{"!done"})
+		}
+
+		if words[0] == "/login" {
+			if attributes["name"] != mockUsername || attributes["password"] != mockPassword {
+				trap("invalid user name or password (6)")
+				continue
+			}
+			loggedIn = true
+			reply([]string{"!done"})
+			continue
+		}
+		if !loggedIn {
+			trap("not logged in")
+			continue
+		}
+
+		switch words[0] {
+		case "/system/resource/print":
+			reply([]string{"!re", "=board-name=RB5009UG+S+", "=version=7.16 (stable)"}, []string{"!done"})
+		case "/ip/dns/static/print":
+			var sentences [][]string
+			for _, record := range m.records {
+				if !matchesQuery(record, words[1:]) {
+					continue
+				}
+				sentence := []string{"!re"}
+				for key, value := range record {
+					sentence = append(sentence, fmt.Sprintf("=%s=%s", key, value))
+				}
+				sentences = append(sentences, sentence)
+			}
+			reply(append(sentences, []string{"!done"})...)
+		case "/ip/dns/static/add":
+			m.nextID++
+			attributes[".id"] = fmt.Sprintf("*%X", m.nextID)
+			m.records = append(m.records, attributes)
+			reply([]string{"!done", "=ret=" + attributes[".id"]})
+		case "/ip/dns/static/set":
+			index := slices.IndexFunc(m.records, func(record map[string]string) bool {
+				return record[".id"] == attributes[".id"]
+			})
+			if index < 0 {
+				trap("no such item")
+				continue
+			}
+			maps.Copy(m.records[index], attributes)
+			reply([]string{"!done"})
+		case "/ip/dns/static/remove":
+			index := slices.IndexFunc(m.records, func(record map[string]string) bool {
+				return record[".id"] == attributes[".id"]
+			})
+			if index < 0 {
+				trap("no such item")
+				continue
+			}
+			m.records = slices.Delete(m.records, index, index+1)
+			reply([]string{"!done"})
+		default:
+			trap("no such command")
+		}
+	}
+}
+
+// matchesQuery evaluates the query words of a print command against a record
+func matchesQuery(record map[string]string, words []string) bool {
+	var stack []bool
+	for _, word := range words {
+		switch {
+		case word == "?#|" || word == "?#&":
+			a, b := stack[len(stack)-2], stack[len(stack)-1]
+			stack = stack[:len(stack)-2]
+			if word == "?#|" {
+				stack = append(stack, a || b)
+			} else {
+				stack = append(stack, a && b)
+			}
+		case strings.HasPrefix(word, "?-"):
+			_, ok := record[word[2:]]
+			stack = append(stack, !ok)
+		case strings.HasPrefix(word, "?"):
+			key, value, _ := strings.Cut(word[1:], "=")
+			stack = append(stack, record[key] == value)
+		}
+	}
+
+	for _, matched := range stack {
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAPITransport(t *testing.T) {
+	router := newMockAPIRouter(t)
+
+	client, err := NewMikrotikClient(&MikrotikConnectionConfig{
+		BaseUrls:  []string{router.url()},
+		Username:  mockUsername,
+		Password:  mockPassword,
+		Transport: "api",
+	}, &MikrotikDefaults{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	info, err := client.GetSystemInfo(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if info.BoardName != "RB5009UG+S+" || info.Version != "7.16 (stable)" {
+		t.Errorf("Unexpected system info: %+v", info)
+	}
+
+	created, err := client.CreateDNSRecord(context.Background(), &endpoint.Endpoint{
+		DNSName:    "www.example.com",
+		RecordType: "CNAME",
+		Targets:    endpoint.NewTargets("example.com"),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(created) != 1 || created[0].ID == "" || created[0].CName != "example.com" {
+		t.Errorf("Unexpected created records: %+v", created)
+	}
+
+	_, err = client.CreateDNSRecord(context.Background(), &endpoint.Endpoint{
+		DNSName:    "example.com",
+		RecordType: "A",
+		Targets:    endpoint.NewTargets("192.0.2.1"),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	before, after, err := client.UpdateDNSRecord(context.Background(),
+		endpoint.NewEndpoint("example.com", "A", "192.0.2.1"),
+		endpoint.NewEndpoint("example.com", "A", "192.0.2.2").WithProviderSpecific("comment", "updated"),
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if before.ID != after.ID || after.Address != "192.0.2.2" || after.Comment != "updated" {
+		t.Errorf("Expected the record to be updated in place, got %+v -> %+v", before, after)
+	}
+
+	records, err := client.GetAllDNSRecords(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d: %+v", len(records), records)
+	}
+
+	_, err = client.DeleteDNSRecord(context.Background(), &endpoint.Endpoint{
+		DNSName:    "www.example.com",
+		RecordType: "CNAME",
+		Targets:    endpoint.NewTargets("example.com"),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	records, err = client.GetAllDNSRecords(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(records) != 1 || records[0].Name != "example.com" || records[0].Address != "192.0.2.2" {
+		t.Errorf("Expected only the A record to be left, got %+v", records)
+	}
+}
+
+func TestAPITransportLoginFailure(t *testing.T) {
+	router := newMockAPIRouter(t)
+
+	client, err := NewMikrotikClient(&MikrotikConnectionConfig{
+		BaseUrls:  []string{router.url()},
+		Username:  mockUsername,
+		Password:  "wrong",
+		Transport: "api",
+	}, &MikrotikDefaults{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	if _, err := client.GetSystemInfo(context.Background()); err == nil || !strings.Contains(err.Error(), "login failed") {
+		t.Errorf("Expected login error, got %v", err)
+	}
+}
+
+func TestAPITransportTimeout(t *testing.T) {
+	// a router that accepts connections but never replies
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	client, err := NewMikrotikClient(&MikrotikConnectionConfig{
+		BaseUrls:       []string{"api://" + listener.Addr().String()},
+		Transport:      "api",
+		RequestTimeout: 50 * time.Millisecond,
+	}, &MikrotikDefaults{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	start := time.Now()
+	if _, err := client.GetSystemInfo(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline exceeded error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the request to time out quickly, took %v", elapsed)
+	}
+}
@@ -0,0 +1,202 @@
+package mikrotik
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// planChanges checks that the changes of a batch do not contradict each other or the records left untouched on the
+// routers, and orders them so that the targets of CNAME records are applied before the records pointing to them. The
+// records of a name after the batch are built from its created and updated endpoints, along with the records read by
+// the last Records call that are neither deleted nor updated. They contradict each other if:
+//   - the same record is created or updated more than once
+//   - a CNAME is next to records of other types than TXT
+//   - CNAME records point to each other in a loop
+//
+// All changes of a name with contradicting endpoints are removed from the batch, so that its records are left as they
+// are, and an *EndpointError wrapping ErrConflict is returned for each of the offending endpoints.
+func (p *MikrotikProvider) planChanges(changes *plan.Changes) (*plan.Changes, []error) {
+	var errs []error
+	rejected := map[string]bool{}
+	reject := func(ep *endpoint.Endpoint, reason string, args ...any) {
+		err := &EndpointError{Endpoint: ep, Err: fmt.Errorf("%w: %s", ErrConflict, fmt.Sprintf(reason, args...))}
+		log.Warnf("Skipping the changes of %s: %v", ep.DNSName, err)
+		errs = append(errs, err)
+		rejected[p.nameKey(ep)] = true
+	}
+
+	resulting := slices.Concat(changes.Create, changes.UpdateNew)
+	untouched := p.untouchedRecords(changes)
+
+	planned := map[string]bool{}
+	types := map[string][]string{}
+	for _, ep := range resulting {
+		if planned[p.endpointKey(ep)] {
+			reject(ep, "%s record of %s is planned more than once", ep.RecordType, ep.DNSName)
+			continue
+		}
+		planned[p.endpointKey(ep)] = true
+	}
+
+	// The TXT registry of external-dns stores the owner of a CNAME in a TXT record of the same name, unless a prefix or
+	// suffix is set, so TXT records are allowed next to it
+	for _, ep := range slices.Concat(resulting, untouched) {
+		if ep.RecordType != "TXT" && !slices.Contains(types[p.nameKey(ep)], ep.RecordType) {
+			types[p.nameKey(ep)] = append(types[p.nameKey(ep)], ep.RecordType)
+		}
+	}
+
+	// Records planned next to a CNAME left on the routers are rejected, otherwise the planned CNAME is
+	plannedCNAME := map[string]bool{}
+	for _, ep := range resulting {
+		if ep.RecordType == "CNAME" {
+			plannedCNAME[p.nameKey(ep)] = true
+		}
+	}
+	for _, ep := range resulting {
+		recordTypes := types[p.nameKey(ep)]
+		if !slices.Contains(recordTypes, "CNAME") || len(recordTypes) < 2 {
+			continue
+		}
+		switch {
+		case ep.RecordType == "CNAME":
+			others := slices.DeleteFunc(slices.Clone(recordTypes), func(recordType string) bool { return recordType == "CNAME" })
+			reject(ep, "CNAME record of %s cannot be next to its %s records", ep.DNSName, strings.Join(others, ", "))
+		case ep.RecordType != "TXT" && !plannedCNAME[p.nameKey(ep)]:
+			reject(ep, "%s record of %s cannot be next to its CNAME record", ep.RecordType, ep.DNSName)
+		}
+	}
+
+	_, cyclic := dependencyOrder(slices.Concat(resulting, untouched))
+	for _, ep := range cyclic {
+		if slices.Contains(resulting, ep) {
+			reject(ep, "CNAME record of %s points to itself through other CNAME records", ep.DNSName)
+		}
+	}
+
+	if len(errs) == 0 {
+		create, _ := dependencyOrder(changes.Create)
+		updateNew, _ := dependencyOrder(changes.UpdateNew)
+		return &plan.Changes{Create: create, Delete: changes.Delete, UpdateOld: changes.UpdateOld, UpdateNew: updateNew}, nil
+	}
+
+	keep := func(endpoints []*endpoint.Endpoint) []*endpoint.Endpoint {
+		kept := []*endpoint.Endpoint{}
+		for _, ep := range endpoints {
+			if !rejected[p.nameKey(ep)] {
+				kept = append(kept, ep)
+			}
+		}
+		sorted, _ := dependencyOrder(kept)
+		return sorted
+	}
+
+	return &plan.Changes{
+		Create:    keep(changes.Create),
+		Delete:    keep(changes.Delete),
+		UpdateOld: keep(changes.UpdateOld),
+		UpdateNew: keep(changes.UpdateNew),
+	}, errs
+}
+
+// untouchedRecords returns the records read by the last Records call that are neither deleted nor updated by the
+// changes
+func (p *MikrotikProvider) untouchedRecords(changes *plan.Changes) []*endpoint.Endpoint {
+	touched := map[string]bool{}
+	for _, ep := range slices.Concat(changes.Delete, changes.UpdateOld, changes.UpdateNew) {
+		touched[p.endpointKey(ep)] = true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	untouched := []*endpoint.Endpoint{}
+	for _, ep := range p.records {
+		if !touched[p.endpointKey(ep)] {
+			untouched = append(untouched, ep)
+		}
+	}
+	return untouched
+}
+
+// nameKey returns the key of the name of an endpoint, shared by the records of all its types
+func (p *MikrotikProvider) nameKey(ep *endpoint.Endpoint) string {
+	return fmt.Sprintf("%s|%s", normalizeDomain(ep.DNSName), p.getProviderSpecificOrDefault(ep, "regexp", ""))
+}
+
+// dependencyOrder sorts the endpoints so that the endpoints of the names CNAME records point to come before them, and
+// keeps the order of the endpoints otherwise. The CNAME endpoints pointing to each other in a loop are returned as well.
+func dependencyOrder(endpoints []*endpoint.Endpoint) ([]*endpoint.Endpoint, []*endpoint.Endpoint) {
+	byName := map[string][]*endpoint.Endpoint{}
+	for _, ep := range endpoints {
+		name := normalizeDomain(ep.DNSName)
+		byName[name] = append(byName[name], ep)
+	}
+
+	visited := map[*endpoint.Endpoint]bool{}
+	sorted := make([]*endpoint.Endpoint, 0, len(endpoints))
+	var cyclic []*endpoint.Endpoint
+
+	// path holds the endpoints being visited, each depending on the one before it
+	var path []*endpoint.Endpoint
+	var visit func(ep *endpoint.Endpoint)
+	visit = func(ep *endpoint.Endpoint) {
+		if visited[ep] {
+			return
+		}
+		if i := slices.Index(path, ep); i >= 0 {
+			for _, looped := range path[i:] {
+				if !slices.Contains(cyclic, looped) {
+					cyclic = append(cyclic, looped)
+				}
+			}
+			return
+		}
+
+		path = append(path, ep)
+		if ep.RecordType == "CNAME" {
+			for _, target := range ep.Targets {
+				for _, dependency := range byName[normalizeDomain(target)] {
+					visit(dependency)
+				}
+			}
+		}
+		path = path[:len(path)-1]
+
+		visited[ep] = true
+		sorted = append(sorted, ep)
+	}
+
+	for _, ep := range endpoints {
+		visit(ep)
+	}
+	return sorted, cyclic
+}
+
+// lateUpdates splits the updates into the ones applied before the endpoints are created, and the ones applied after
+// them: updates of CNAME records pointing to a name that is created, so that they never point to a name that does not
+// resolve yet
+func lateUpdates(updates []endpointUpdate, creates []*endpoint.Endpoint) ([]endpointUpdate, []endpointUpdate) {
+	created := map[string]bool{}
+	for _, ep := range creates {
+		created[normalizeDomain(ep.DNSName)] = true
+	}
+
+	early := []endpointUpdate{}
+	late := []endpointUpdate{}
+	for _, update := range updates {
+		if update.new.RecordType == "CNAME" && slices.ContainsFunc(update.new.Targets, func(target string) bool {
+			return created[normalizeDomain(target)]
+		}) {
+			late = append(late, update)
+		} else {
+			early = append(early, update)
+		}
+	}
+	return early, late
+}
@@ -0,0 +1,205 @@
+package mikrotik
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestPlanChanges(t *testing.T) {
+	testCases := []struct {
+		name             string
+		records          []*endpoint.Endpoint
+		changes          *plan.Changes
+		expectedCreate   []string
+		expectedUpdate   []string
+		expectedDelete   []string
+		expectedRejected []string
+	}{
+		{
+			name: "CNAME targets are created first",
+			changes: &plan.Changes{Create: []*endpoint.Endpoint{
+				endpoint.NewEndpoint("www.example.com", "CNAME", "web.example.com"),
+				endpoint.NewEndpoint("web.example.com", "CNAME", "Lb.Example.com."),
+				endpoint.NewEndpoint("other.example.com", "A", "192.0.2.2"),
+				endpoint.NewEndpoint("lb.example.com", "A", "192.0.2.1"),
+			}},
+			expectedCreate: []string{"lb.example.com", "web.example.com", "www.example.com", "other.example.com"},
+		},
+		{
+			name: "Record both created and updated",
+			changes: &plan.Changes{
+				Create:    []*endpoint.Endpoint{endpoint.NewEndpoint("a.example.com", "A", "192.0.2.1"), endpoint.NewEndpoint("b.example.com", "A", "192.0.2.2")},
+				UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpoint("a.example.com", "A", "192.0.2.3")},
+				UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("a.example.com", "A", "192.0.2.4")},
+			},
+			expectedCreate:   []string{"b.example.com"},
+			expectedRejected: []string{"a.example.com"},
+		},
+		{
+			name: "CNAME next to other types",
+			changes: &plan.Changes{
+				Create:    []*endpoint.Endpoint{endpoint.NewEndpoint("a.example.com", "CNAME", "b.example.com")},
+				UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpoint("a.example.com", "AAAA", "2001:db8::1")},
+				UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("a.example.com", "AAAA", "2001:db8::2")},
+				Delete:    []*endpoint.Endpoint{endpoint.NewEndpoint("a.example.com", "A", "192.0.2.1"), endpoint.NewEndpoint("c.example.com", "A", "192.0.2.3")},
+			},
+			expectedDelete:   []string{"c.example.com"},
+			expectedRejected: []string{"a.example.com"},
+		},
+		{
+			name: "CNAME next to its TXT registry record",
+			changes: &plan.Changes{Create: []*endpoint.Endpoint{
+				endpoint.NewEndpoint("a.example.com", "CNAME", "b.example.com"),
+				endpoint.NewEndpoint("a.example.com", "TXT", "heritage=external-dns,external-dns/owner=default"),
+			}},
+			expectedCreate: []string{"a.example.com", "a.example.com"},
+		},
+		{
+			name: "CNAME replacing other types",
+			changes: &plan.Changes{
+				Create: []*endpoint.Endpoint{endpoint.NewEndpoint("a.example.com", "CNAME", "b.example.com")},
+				Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("a.example.com", "A", "192.0.2.1")},
+			},
+			expectedCreate: []string{"a.example.com"},
+			expectedDelete: []string{"a.example.com"},
+		},
+		{
+			name: "CNAME loop",
+			changes: &plan.Changes{
+				Create: []*endpoint.Endpoint{
+					endpoint.NewEndpoint("a.example.com", "CNAME", "b.example.com"),
+					endpoint.NewEndpoint("c.example.com", "A", "192.0.2.3"),
+				},
+				UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpoint("b.example.com", "CNAME", "c.example.com")},
+				UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpoint("b.example.com", "CNAME", "a.example.com")},
+			},
+			expectedCreate:   []string{"c.example.com"},
+			expectedRejected: []string{"a.example.com", "b.example.com"},
+		},
+		{
+			name:             "Record next to an existing CNAME",
+			records:          []*endpoint.Endpoint{endpoint.NewEndpoint("a.example.com", "CNAME", "b.example.com")},
+			changes:          &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("a.example.com", "A", "192.0.2.1"), endpoint.NewEndpoint("c.example.com", "A", "192.0.2.3")}},
+			expectedCreate:   []string{"c.example.com"},
+			expectedRejected: []string{"a.example.com"},
+		},
+		{
+			name:    "Record replacing an existing CNAME",
+			records: []*endpoint.Endpoint{endpoint.NewEndpoint("a.example.com", "CNAME", "b.example.com")},
+			changes: &plan.Changes{
+				Create: []*endpoint.Endpoint{endpoint.NewEndpoint("a.example.com", "A", "192.0.2.1")},
+				Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("a.example.com", "CNAME", "b.example.com")},
+			},
+			expectedCreate: []string{"a.example.com"},
+			expectedDelete: []string{"a.example.com"},
+		},
+		{
+			name:             "CNAME loop through an existing CNAME",
+			records:          []*endpoint.Endpoint{endpoint.NewEndpoint("b.example.com", "CNAME", "a.example.com")},
+			changes:          &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("a.example.com", "CNAME", "b.example.com")}},
+			expectedRejected: []string{"a.example.com"},
+		},
+	}
+
+	names := func(endpoints []*endpoint.Endpoint) []string {
+		result := []string{}
+		for _, ep := range endpoints {
+			result = append(result, ep.DNSName)
+		}
+		return result
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mikrotikProvider := &MikrotikProvider{records: tc.records}
+			changes, errs := mikrotikProvider.planChanges(tc.changes)
+
+			if created := names(changes.Create); !slices.Equal(created, tc.expectedCreate) {
+				t.Errorf("Expected creates %v, got %v", tc.expectedCreate, created)
+			}
+			if updated := names(changes.UpdateNew); !slices.Equal(updated, tc.expectedUpdate) {
+				t.Errorf("Expected updates %v, got %v", tc.expectedUpdate, updated)
+			}
+			if len(changes.UpdateOld) != len(changes.UpdateNew) {
+				t.Errorf("Expected the old and new versions of updates to be kept together, got %v and %v", changes.UpdateOld, changes.UpdateNew)
+			}
+			if deleted := names(changes.Delete); !slices.Equal(deleted, tc.expectedDelete) {
+				t.Errorf("Expected deletes %v, got %v", tc.expectedDelete, deleted)
+			}
+
+			var rejected []string
+			for _, err := range errs {
+				var endpointErr *EndpointError
+				if !errors.As(err, &endpointErr) || !errors.Is(err, ErrConflict) {
+					t.Errorf("Expected a conflicting endpoint error, got %v", err)
+					continue
+				}
+				if !slices.Contains(rejected, endpointErr.Endpoint.DNSName) {
+					rejected = append(rejected, endpointErr.Endpoint.DNSName)
+				}
+			}
+			slices.Sort(rejected)
+			if !slices.Equal(rejected, tc.expectedRejected) {
+				t.Errorf("Expected rejected names %v, got %v", tc.expectedRejected, rejected)
+			}
+		})
+	}
+}
+
+func TestApplyChangesPlansBatch(t *testing.T) {
+	router := newMockRouter(t)
+	mikrotikProvider := &MikrotikProvider{
+		clients:  []*MikrotikApiClient{router.client(t, "router")},
+		defaults: &MikrotikDefaults{DefaultTTL: 3600},
+	}
+
+	err := mikrotikProvider.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("www.example.com", "CNAME", 3600, "web.example.com"),
+			endpoint.NewEndpointWithTTL("mail.example.com", "CNAME", 3600, "web.example.com"),
+			endpoint.NewEndpointWithTTL("mail.example.com", "A", 3600, "192.0.2.2"),
+		},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("web.example.com", "A", 3600, "192.0.2.1")},
+	})
+
+	var providerErr *ProviderError
+	if !errors.As(err, &providerErr) || providerErr.Kind() != ErrorKindConflict {
+		t.Fatalf("Expected a conflict error, got %v", err)
+	}
+
+	// the target of the CNAME created from the update is created before it, and the conflicting name is skipped
+	expected := []string{"PUT web.example.com A", "PUT www.example.com CNAME"}
+	if writes := router.writeLog(); !slices.Equal(writes, expected) {
+		t.Errorf("Expected requests %v, got %v", expected, writes)
+	}
+}
+
+func TestApplyChangesUpdatesCNAMEAfterTarget(t *testing.T) {
+	router := newMockRouter(t,
+		DNSRecord{ID: "*1", Name: "www.example.com", Type: "CNAME", CName: "old.example.com", TTL: "1h"},
+		DNSRecord{ID: "*2", Name: "old.example.com", Address: "192.0.2.1", TTL: "1h"},
+	)
+	mikrotikProvider := &MikrotikProvider{
+		clients:  []*MikrotikApiClient{router.client(t, "router")},
+		defaults: &MikrotikDefaults{DefaultTTL: 3600},
+	}
+
+	err := mikrotikProvider.ApplyChanges(context.Background(), &plan.Changes{
+		Create:    []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("new.example.com", "A", 3600, "192.0.2.2")},
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("www.example.com", "CNAME", 3600, "old.example.com")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("www.example.com", "CNAME", 3600, "new.example.com")},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []string{"PUT new.example.com A", "PATCH www.example.com CNAME"}
+	if writes := router.writeLog(); !slices.Equal(writes, expected) {
+		t.Errorf("Expected requests %v, got %v", expected, writes)
+	}
+}
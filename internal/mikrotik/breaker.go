@@ -0,0 +1,151 @@
+package mikrotik
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrCircuitOpen is returned instead of sending a request to a router whose circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// circuitState is the state of a circuit breaker, as reported by the mikrotik_circuit_breaker_state metric
+type circuitState int
+
+const (
+	// circuitClosed lets all requests through
+	circuitClosed circuitState = iota
+	// circuitOpen fails all requests without sending them, until the cooldown has passed
+	circuitOpen
+	// circuitHalfOpen lets a single probe request through, which closes the circuit if it succeeds
+	circuitHalfOpen
+)
+
+func (s circuitState) String() string {
+	switch s {
+	case circuitClosed:
+		return "closed"
+	case circuitOpen:
+		return "open"
+	case circuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("unknown (%d)", int(s))
+	}
+}
+
+// circuitBreaker stops sending requests to a router after repeated failures, so that an unreachable router fails fast
+// instead of every request waiting for its timeouts and retries. Once the cooldown has passed, a single request is let
+// through to probe the router. A threshold of 0 disables the breaker.
+type circuitBreaker struct {
+	router    string
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// newCircuitBreaker creates a closed circuit breaker for the given router
+func newCircuitBreaker(router string, threshold int, cooldown time.Duration) *circuitBreaker {
+	b := &circuitBreaker{router: router, threshold: threshold, cooldown: cooldown}
+	b.report()
+	return b
+}
+
+// allow checks if a request may be sent to the router. Every allowed request must be followed by a call to success,
+// failure or abort.
+func (b *circuitBreaker) allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if retryAt := b.openedAt.Add(b.cooldown); time.Now().Before(retryAt) {
+			return fmt.Errorf("not sending request to %s until %s: %w", b.router, retryAt.Format(time.RFC3339), ErrCircuitOpen)
+		}
+		log.Infof("circuit breaker of %s is half-open, probing the router", b.router)
+		b.state = circuitHalfOpen
+		b.probing = true
+		b.report()
+	case circuitHalfOpen:
+		if b.probing {
+			return fmt.Errorf("not sending request to %s while probing it: %w", b.router, ErrCircuitOpen)
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// success records that the router answered a request, closing the circuit
+func (b *circuitBreaker) success() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.probing = false
+	if b.state != circuitClosed {
+		log.Infof("circuit breaker of %s is closed, the router is reachable again", b.router)
+		b.state = circuitClosed
+		b.report()
+	}
+}
+
+// failure records that a request to the router failed, opening the circuit once the threshold is reached or if the
+// request was probing the router
+func (b *circuitBreaker) failure() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == circuitHalfOpen || (b.state == circuitClosed && b.failures >= b.threshold) {
+		log.Warnf("circuit breaker of %s is open after %d failed requests, failing requests for %v", b.router, b.failures, b.cooldown)
+		b.state = circuitOpen
+		b.openedAt = time.Now()
+		b.report()
+	}
+}
+
+// abort records that a request was cancelled before the router could answer it, which says nothing about the router
+func (b *circuitBreaker) abort() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// State returns the current state of the circuit breaker
+func (b *circuitBreaker) State() circuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+// report updates the metrics with the state of the circuit breaker. Must be called with the lock held.
+func (b *circuitBreaker) report() {
+	circuitBreakerStateGauge.WithLabelValues(b.router).Set(float64(b.state))
+}
@@ -0,0 +1,75 @@
+// breaker_test.go
+package mikrotik
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	breaker := newCircuitBreaker("router", 2, 50*time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		if err := breaker.allow(); err != nil {
+			t.Fatalf("Expected request %d to be allowed, got %v", i+1, err)
+		}
+		breaker.failure()
+	}
+	if state := breaker.State(); state != circuitOpen {
+		t.Fatalf("Expected the breaker to be open after 2 failures, got %s", state)
+	}
+	if err := breaker.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected requests to be rejected while open, got %v", err)
+	}
+
+	// once the cooldown has passed, a single probe is let through
+	time.Sleep(60 * time.Millisecond)
+	if err := breaker.allow(); err != nil {
+		t.Fatalf("Expected the probe to be allowed, got %v", err)
+	}
+	if state := breaker.State(); state != circuitHalfOpen {
+		t.Fatalf("Expected the breaker to be half-open, got %s", state)
+	}
+	if err := breaker.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected requests to be rejected while probing, got %v", err)
+	}
+
+	// a failed probe opens the circuit again right away
+	breaker.failure()
+	if state := breaker.State(); state != circuitOpen {
+		t.Fatalf("Expected the breaker to be open after a failed probe, got %s", state)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if err := breaker.allow(); err != nil {
+		t.Fatalf("Expected the probe to be allowed, got %v", err)
+	}
+	breaker.success()
+	if state := breaker.State(); state != circuitClosed {
+		t.Fatalf("Expected the breaker to be closed after a successful probe, got %s", state)
+	}
+
+	// a single failure after closing does not open it
+	if err := breaker.allow(); err != nil {
+		t.Fatalf("Expected the request to be allowed, got %v", err)
+	}
+	breaker.failure()
+	if state := breaker.State(); state != circuitClosed {
+		t.Errorf("Expected the breaker to stay closed, got %s", state)
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	breaker := newCircuitBreaker("router", 0, time.Minute)
+
+	for i := 0; i < 10; i++ {
+		if err := breaker.allow(); err != nil {
+			t.Fatalf("Expected request %d to be allowed, got %v", i+1, err)
+		}
+		breaker.failure()
+	}
+	if state := breaker.State(); state != circuitClosed {
+		t.Errorf("Expected a disabled breaker to stay closed, got %s", state)
+	}
+}
@@ -1,40 +1,179 @@
-// Rest API Docs: https://help.mikrotik.com/docs/display/ROS/REST+API
-
 package mikrotik
 
 import (
-	"bytes"
-	"crypto/tls"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
-	"io"
+	"maps"
+	"math/rand/v2"
+	"net"
 	"net/http"
-	"net/http/cookiejar"
-
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caarlos0/env/v11"
 	log "github.com/sirupsen/logrus"
-	"golang.org/x/net/publicsuffix"
 	"sigs.k8s.io/external-dns/endpoint"
 )
 
 type MikrotikDefaults struct {
-	DefaultTTL     int64  `env:"MIKROTIK_DEFAULT_TTL" envDefault:"3600"`
+	DefaultTTL int64 `env:"MIKROTIK_DEFAULT_TTL" envDefault:"3600"`
+
+	// DefaultComment is a Go template rendered with the data of each endpoint, i.e. {{.Labels.resource}} ({{.RecordType}})
 	DefaultComment string `env:"MIKROTIK_DEFAULT_COMMENT" envDefault:""`
+	// Rendered default comments are cut to CommentMaxLength bytes, 0 for no limit
+	CommentMaxLength int `env:"MIKROTIK_COMMENT_MAX_LENGTH" envDefault:"255"`
+
+	// Domains override the defaults for the endpoints in a domain, the first matching domain wins (see ReadDomainDefaults)
+	Domains []*DomainDefaults
 }
 
 // MikrotikConnectionConfig holds the connection details for the API client
 type MikrotikConnectionConfig struct {
-	BaseUrl       string `env:"MIKROTIK_BASEURL,notEmpty"`
-	Username      string `env:"MIKROTIK_USERNAME,notEmpty"`
-	Password      string `env:"MIKROTIK_PASSWORD,notEmpty"`
-	SkipTLSVerify bool   `env:"MIKROTIK_SKIP_TLS_VERIFY" envDefault:"false"`
+	Name           string        `env:"MIKROTIK_NAME"`
+	BaseUrls       []string      `env:"MIKROTIK_BASEURL,notEmpty" envSeparator:","`
+	Username       string        `env:"MIKROTIK_USERNAME,notEmpty"`
+	Password       string        `env:"MIKROTIK_PASSWORD,notEmpty"`
+	SkipTLSVerify  bool          `env:"MIKROTIK_SKIP_TLS_VERIFY" envDefault:"false"`
+	Transport      string        `env:"MIKROTIK_TRANSPORT" envDefault:"rest"`
+	ConnectTimeout time.Duration `env:"MIKROTIK_CONNECT_TIMEOUT" envDefault:"10s"`
+	RequestTimeout time.Duration `env:"MIKROTIK_REQUEST_TIMEOUT" envDefault:"30s"`
+
+	// Failed requests are retried with a jittered exponential backoff, up to RetryAttempts attempts in total
+	RetryAttempts   int           `env:"MIKROTIK_RETRY_ATTEMPTS" envDefault:"3"`
+	RetryBackoff    time.Duration `env:"MIKROTIK_RETRY_BACKOFF" envDefault:"500ms"`
+	RetryMaxBackoff time.Duration `env:"MIKROTIK_RETRY_MAX_BACKOFF" envDefault:"10s"`
+
+	// After CircuitBreakerThreshold consecutive failed requests, requests fail fast for CircuitBreakerCooldown
+	CircuitBreakerThreshold int           `env:"MIKROTIK_CIRCUIT_BREAKER_THRESHOLD" envDefault:"5"`
+	CircuitBreakerCooldown  time.Duration `env:"MIKROTIK_CIRCUIT_BREAKER_COOLDOWN" envDefault:"30s"`
+}
+
+// RouterName returns the name used to identify the router in logs and errors, falling back to its first URL
+func (c *MikrotikConnectionConfig) RouterName() string {
+	if c.Name != "" || len(c.BaseUrls) == 0 {
+		return c.Name
+	}
+	return c.BaseUrls[0]
+}
+
+// routerEnvRegex matches the indexed environment variables used to configure multiple routers (i.e. MIKROTIK_1_BASEURL)
+var routerEnvRegex = regexp.MustCompile(`^MIKROTIK_(\d+)_(.+)$`)
+
+// ReadConnectionConfigs reads the connection details of all routers from the given environment (as returned by os.Environ).
+// Multiple routers are configured via indexed variables (i.e. MIKROTIK_1_BASEURL, MIKROTIK_2_BASEURL), with the unindexed
+// variables (i.e. MIKROTIK_USERNAME) acting as shared values for all of them. Without indexed variables, a single router is read.
+func ReadConnectionConfigs(environ []string) ([]*MikrotikConnectionConfig, error) {
+	shared := map[string]string{}
+	indexed := map[int]map[string]string{}
+	for _, variable := range environ {
+		key, value, _ := strings.Cut(variable, "=")
+
+		match := routerEnvRegex.FindStringSubmatch(key)
+		if match == nil {
+			shared[key] = value
+			continue
+		}
+
+		index, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid router index in %s: %v", key, err)
+		}
+		if indexed[index] == nil {
+			indexed[index] = map[string]string{}
+		}
+		indexed[index]["MIKROTIK_"+match[2]] = value
+	}
+
+	if len(indexed) == 0 {
+		config := &MikrotikConnectionConfig{}
+		if err := env.ParseWithOptions(config, env.Options{Environment: shared}); err != nil {
+			return nil, err
+		}
+		return []*MikrotikConnectionConfig{config}, nil
+	}
+
+	indices := make([]int, 0, len(indexed))
+	for index := range indexed {
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+
+	configs := make([]*MikrotikConnectionConfig, 0, len(indices))
+	for _, index := range indices {
+		environment := maps.Clone(shared)
+		maps.Copy(environment, indexed[index])
+
+		config := &MikrotikConnectionConfig{}
+		if err := env.ParseWithOptions(config, env.Options{Environment: environment}); err != nil {
+			return nil, fmt.Errorf("reading configuration of router %d failed: %w", index, err)
+		}
+		configs = append(configs, config)
+	}
+
+	return configs, nil
 }
 
-// MikrotikApiClient encapsulates the client configuration and HTTP client
+// unhealthyCooldown is how long an API URL that failed is skipped before it is tried again
+const unhealthyCooldown = 30 * time.Second
+
+// transport sends commands to a router over one of its API URLs.
+// Items are exchanged in their REST API JSON representation, whatever the underlying protocol. Calls are aborted once their context is done.
+type transport interface {
+	// get fetches the items of a menu (i.e. ip/dns/static) matching the query into out.
+	// Multiple values of a query parameter match any of them.
+	get(ctx context.Context, path string, query url.Values, out any) error
+	// add creates an item in a menu, and fetches the created item into out
+	add(ctx context.Context, path string, item any, out any) error
+	// set changes the given fields of an item in a menu by its ID, and fetches the updated item into out
+	set(ctx context.Context, path, id string, fields any, out any) error
+	// remove deletes an item from a menu by its ID
+	remove(ctx context.Context, path, id string) error
+}
+
+// newTransport creates the transport selected in the configuration for the given API URL
+func newTransport(baseUrl string, config *MikrotikConnectionConfig) (transport, error) {
+	switch config.Transport {
+	case "rest", "":
+		return newRestTransport(baseUrl, config)
+	case "api":
+		return newAPITransport(baseUrl, config)
+	default:
+		return nil, fmt.Errorf("unsupported transport %q, expected rest or api", config.Transport)
+	}
+}
+
+// MikrotikApiClient encapsulates the client configuration and the transports to the router
 type MikrotikApiClient struct {
 	*MikrotikDefaults
 	*MikrotikConnectionConfig
-	*http.Client
+
+	// One transport per API URL, in the same order as BaseUrls
+	transports []transport
+
+	// The API URL requests are sent to, as an index in BaseUrls, along with the ones that recently failed.
+	// The client sticks to the active URL for as long as it works and only fails over to the next one on errors.
+	mu        sync.Mutex
+	active    int
+	unhealthy map[int]time.Time
+
+	// The records seen by the last GetAllDNSRecords call, so that their IDs can be reused when changing them
+	records []DNSRecord
+
+	breaker *circuitBreaker
+
+	// In dry-run mode, the requests changing records are recorded instead of being sent
+	dryRun *dryRunRecorder
+
+	// In ownership mode, the tag marking the records created by the webhook in their comment.
+	// Records without it are never changed nor deleted.
+	ownerMarker string
 }
 
 // MikrotikSystemInfo represents MikroTik system information
@@ -60,194 +199,715 @@ type MikrotikSystemInfo struct {
 	WriteSectTotal       string `json:"write-sect-total"`
 }
 
+// MikrotikSystemIdentity represents the name given to a MikroTik router
+// https://help.mikrotik.com/docs/display/ROS/Identity
+type MikrotikSystemIdentity struct {
+	Name string `json:"name"`
+}
+
+// MikrotikDNSForwarder represents a named set of upstream DNS servers that FWD records can forward to
+// https://help.mikrotik.com/docs/display/ROS/DNS#DNS-Forwarders
+type MikrotikDNSForwarder struct {
+	ID         string `json:".id,omitempty"`
+	Name       string `json:"name"`
+	DnsServers string `json:"dns-servers,omitempty"`
+}
+
 // NewMikrotikClient creates a new instance of MikrotikApiClient
 func NewMikrotikClient(config *MikrotikConnectionConfig, defaults *MikrotikDefaults) (*MikrotikApiClient, error) {
 	log.Infof("creating a new Mikrotik API Client")
 
-	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
-	if err != nil {
-		log.Errorf("failed to create cookie jar: %v", err)
-		return nil, err
-	}
-
 	client := &MikrotikApiClient{
 		MikrotikDefaults:         defaults,
 		MikrotikConnectionConfig: config,
-		Client: &http.Client{
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{
-					InsecureSkipVerify: config.SkipTLSVerify,
-				},
-			},
-			Jar: jar,
-		},
+		unhealthy:                map[int]time.Time{},
+		breaker:                  newCircuitBreaker(config.RouterName(), config.CircuitBreakerThreshold, config.CircuitBreakerCooldown),
+	}
+	for _, baseUrl := range config.BaseUrls {
+		transport, err := newTransport(baseUrl, config)
+		if err != nil {
+			log.Errorf("failed to create transport: %v", err)
+			return nil, err
+		}
+		client.transports = append(client.transports, &instrumentedTransport{transport: transport, router: config.RouterName()})
 	}
+	client.mu.Lock()
+	client.reportActiveURL()
+	client.mu.Unlock()
 
 	return client, nil
 }
 
 // GetSystemInfo fetches system information from the MikroTik API
-func (c *MikrotikApiClient) GetSystemInfo() (*MikrotikSystemInfo, error) {
+func (c *MikrotikApiClient) GetSystemInfo(ctx context.Context) (*MikrotikSystemInfo, error) {
 	log.Debugf("fetching system information.")
 
-	// Send the request
-	resp, err := c.doRequest(http.MethodGet, "system/resource", nil)
+	var info MikrotikSystemInfo
+	err := c.request(ctx, true, func(ctx context.Context, t transport) error {
+		return t.get(ctx, "system/resource", nil, &info)
+	})
 	if err != nil {
 		log.Errorf("error fetching system info: %v", err)
 		return nil, err
 	}
-	defer resp.Body.Close()
+	log.Debugf("got system info: %+v", info)
 
-	// Parse the response
-	var info MikrotikSystemInfo
-	if err = json.NewDecoder(resp.Body).Decode(&info); err != nil {
-		log.Errorf("error decoding response body: %v", err)
+	return &info, nil
+}
+
+// GetSystemIdentity fetches the identity of the router from the MikroTik API
+func (c *MikrotikApiClient) GetSystemIdentity(ctx context.Context) (*MikrotikSystemIdentity, error) {
+	log.Debugf("fetching system identity.")
+
+	var identity MikrotikSystemIdentity
+	err := c.request(ctx, true, func(ctx context.Context, t transport) error {
+		return t.get(ctx, "system/identity", nil, &identity)
+	})
+	if err != nil {
+		log.Errorf("error fetching system identity: %v", err)
 		return nil, err
 	}
-	log.Debugf("got system info: %+v", info)
+	log.Debugf("got system identity: %+v", identity)
 
-	return &info, nil
+	return &identity, nil
 }
 
-// CreateDNSRecord sends a request to create a new DNS record
-func (c *MikrotikApiClient) CreateDNSRecord(endpoint *endpoint.Endpoint) (*DNSRecord, error) {
+// CreateDNSRecord sends requests to create a new DNS record for each of the endpoint targets.
+// If one of them fails, the records created before it are returned along with the error.
+// Records the router rejects because an identical entry already exists adopt that entry instead, and are left out of
+// the returned records since they were not created.
+func (c *MikrotikApiClient) CreateDNSRecord(ctx context.Context, endpoint *endpoint.Endpoint) ([]*DNSRecord, error) {
 	log.Infof("creating DNS record: %+v", endpoint)
 
 	// Convert ExternalDNS to Mikrotik DNS
-	record, err := NewDNSRecord(endpoint)
+	records, err := NewDNSRecords(endpoint)
 	if err != nil {
 		log.Errorf("error converting ExternalDNS endpoint to Mikrotik DNS Record: %v", err)
 		return nil, err
 	}
 
-	// Serialize the data to JSON to be sent to the API
-	jsonBody, err := json.Marshal(record)
+	created := make([]*DNSRecord, 0, len(records))
+	for _, record := range records {
+		err := c.createDNSRecord(ctx, record)
+		if errors.Is(err, ErrAlreadyExists) {
+			var adopted bool
+			if adopted, err = c.adoptDNSRecord(ctx, record, err); adopted {
+				continue
+			}
+		}
+		if err != nil {
+			return created, err
+		}
+		created = append(created, record)
+	}
+
+	return created, nil
+}
+
+// adoptDNSRecord looks up the entry a record could not be created for because it already exists. If the entry has the
+// same properties as the record, the record takes its ID and true is returned. Otherwise, the creation error is
+// returned.
+func (c *MikrotikApiClient) adoptDNSRecord(ctx context.Context, record *DNSRecord, createErr error) (bool, error) {
+	existing, err := c.findDNSRecord(ctx, record)
 	if err != nil {
-		log.Errorf("error marshalling DNS record: %v", err)
+		return false, fmt.Errorf("%w (looking up the existing entry failed: %v)", createErr, err)
+	}
+	if existing == nil {
+		return false, createErr
+	}
+
+	changed, err := changedFields(existing, record)
+	if err != nil || len(changed) > 0 {
+		log.Warnf("an entry already exists with other properties than the record, not adopting it: %+v", existing)
+		return false, createErr
+	}
+
+	log.Infof("an identical entry already exists, adopting it: %+v", existing)
+	*record = *existing
+	return true, nil
+}
+
+// RestoreDNSRecord recreates a record that was deleted, with the same properties but a new ID
+func (c *MikrotikApiClient) RestoreDNSRecord(ctx context.Context, record *DNSRecord) (*DNSRecord, error) {
+	log.Infof("restoring DNS record: %+v", record)
+
+	restored := *record
+	restored.ID = ""
+	if err := c.createDNSRecord(ctx, &restored); err != nil {
 		return nil, err
 	}
 
-	// Send the request
-	resp, err := c.doRequest(http.MethodPut, "ip/dns/static", bytes.NewReader(jsonBody))
+	return &restored, nil
+}
+
+// createDNSRecord sends a request to create a single Mikrotik DNS record
+func (c *MikrotikApiClient) createDNSRecord(ctx context.Context, record *DNSRecord) error {
+	*record = *c.markOwned(record)
+	if err := c.checkForwardTo(ctx, record); err != nil {
+		return err
+	}
+
+	if c.dryRun != nil {
+		created := *record
+		c.dryRun.record(DryRunOperation{Method: "PUT", Path: "ip/dns/static", Record: &created})
+		log.Infof("dry run, not creating record: %+v", record)
+		return nil
+	}
+
+	// If the request failed in a way it may still have been applied, the record is looked up before sending it again
+	applied := func(ctx context.Context) (bool, error) {
+		existing, err := c.findDNSRecord(ctx, record)
+		if err != nil || existing == nil {
+			return false, err
+		}
+		log.Warnf("record was created despite the failed request, adopting it: %+v", existing)
+		*record = *existing
+		return true, nil
+	}
+
+	err := c.requestChecked(ctx, false, applied, func(ctx context.Context, t transport) error {
+		return t.add(ctx, "ip/dns/static", record, record)
+	})
 	if err != nil {
 		log.Errorf("error creating DNS record: %v", err)
+		return err
+	}
+	log.Infof("created record: %+v", record)
+
+	return nil
+}
+
+// checkForwardTo checks that the forwarder of a FWD record exists.
+// FWD records can forward either to an IP or to a named forwarder, which has to exist on the router.
+func (c *MikrotikApiClient) checkForwardTo(ctx context.Context, record *DNSRecord) error {
+	if record.Type != "FWD" || net.ParseIP(record.ForwardTo) != nil {
+		return nil
+	}
+
+	if _, err := c.lookupDNSForwarder(ctx, record.ForwardTo); err != nil {
+		log.Errorf("failed lookup for DNS forwarder: %v", err)
+		return err
+	}
+	return nil
+}
+
+// UpdateDNSRecord sends a request to update the record of an endpoint in place, changing only the fields that differ.
+// Both endpoints have to point to a single target. The record is returned as it was before and after the update.
+func (c *MikrotikApiClient) UpdateDNSRecord(ctx context.Context, old, new *endpoint.Endpoint) (*DNSRecord, *DNSRecord, error) {
+	log.Infof("updating DNS record: %+v -> %+v", old, new)
+
+	wanted, err := NewDNSRecord(new)
+	if err != nil {
+		log.Errorf("error converting ExternalDNS endpoint to Mikrotik DNS Record: %v", err)
+		return nil, nil, err
+	}
+
+	current, err := c.lookupDNSRecord(ctx, old, nonEmptyTargets(old.Targets).String())
+	if err != nil {
+		log.Errorf("failed lookup for DNS record: %+v", err)
+		return nil, nil, err
+	}
+
+	updated, err := c.PatchDNSRecord(ctx, current, wanted)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return current, updated, nil
+}
+
+// PatchDNSRecord sends a request to change the fields of the current record that differ from the wanted one
+func (c *MikrotikApiClient) PatchDNSRecord(ctx context.Context, current, wanted *DNSRecord) (*DNSRecord, error) {
+	wanted = c.markOwned(wanted)
+	fields, err := changedFields(current, wanted)
+	if err != nil {
+		log.Errorf("error comparing DNS records: %v", err)
 		return nil, err
 	}
-	defer resp.Body.Close()
+	if len(fields) == 0 {
+		log.Debugf("record %s is already up to date", current.ID)
+		return current, nil
+	}
+
+	if _, changed := fields["forward-to"]; changed {
+		if err := c.checkForwardTo(ctx, wanted); err != nil {
+			return nil, err
+		}
+	}
 
-	// Parse the response
-	if err = json.NewDecoder(resp.Body).Decode(&record); err != nil {
-		log.Errorf("Error decoding response body: %v", err)
+	if c.dryRun != nil {
+		c.dryRun.record(DryRunOperation{Method: "PATCH", Path: "ip/dns/static/" + current.ID, Record: current, Fields: fields})
+		log.Infof("dry run, not updating record %s: %v", current.ID, fields)
+		updated := *wanted
+		updated.ID = current.ID
+		return &updated, nil
+	}
+
+	updated := &DNSRecord{}
+	err = c.request(ctx, true, func(ctx context.Context, t transport) error {
+		return t.set(ctx, "ip/dns/static", current.ID, fields, updated)
+	})
+	if err != nil {
+		log.Errorf("error updating DNS record: %v", err)
 		return nil, err
 	}
-	log.Infof("created record: %+v", record)
+	log.Infof("updated record %s: %v", current.ID, fields)
+	c.forgetRecord(current.ID)
 
-	return record, nil
+	return updated, nil
 }
 
 // GetAllDNSRecords fetches all DNS records from the MikroTik API
-func (c *MikrotikApiClient) GetAllDNSRecords() ([]DNSRecord, error) {
+func (c *MikrotikApiClient) GetAllDNSRecords(ctx context.Context) ([]DNSRecord, error) {
 	log.Debugf("fetching all DNS records")
 
-	// Send the request
-	resp, err := c.doRequest(http.MethodGet, "ip/dns/static?type=A,AAAA,CNAME,TXT,MX,SRV,NS", nil)
+	var records []DNSRecord
+	query := url.Values{"type": {"A", "AAAA", "CNAME", "TXT", "MX", "SRV", "NS", "FWD", "NXDOMAIN"}}
+	err := c.request(ctx, true, func(ctx context.Context, t transport) error {
+		return t.get(ctx, "ip/dns/static", query, &records)
+	})
 	if err != nil {
 		log.Errorf("error fetching DNS records: %v", err)
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	// Parse the response
-	var records []DNSRecord
-	if err = json.NewDecoder(resp.Body).Decode(&records); err != nil {
-		log.Errorf("error decoding response body: %v", err)
-		return nil, err
-	}
 	log.Debugf("fetched %d DNS records: %v", len(records), records)
 
+	c.mu.Lock()
+	c.records = slices.Clone(records)
+	c.mu.Unlock()
+
 	return records, nil
 }
 
-// DeleteDNSRecord sends a request to delete a DNS record
-func (c *MikrotikApiClient) DeleteDNSRecord(endpoint *endpoint.Endpoint) error {
+// DeleteDNSRecord sends requests to delete the DNS records for each of the endpoint targets, returning the deleted records.
+// If the endpoint has no targets, the only record matching its name and type is deleted.
+// If one of them fails, the records deleted before it are returned along with the error.
+func (c *MikrotikApiClient) DeleteDNSRecord(ctx context.Context, endpoint *endpoint.Endpoint) ([]*DNSRecord, error) {
 	log.Infof("deleting DNS record: %+v", endpoint)
 
-	// Send the request
-	record, err := c.lookupDNSRecord(endpoint.DNSName, endpoint.RecordType)
-	if err != nil {
-		log.Errorf("failed lookup for DNS record: %+v", err)
-		return err
+	targets := nonEmptyTargets(endpoint.Targets)
+	if len(targets) == 0 {
+		targets = []string{""}
+	}
+
+	var deleted []*DNSRecord
+	for _, target := range targets {
+		// Send the request
+		record, err := c.lookupDNSRecord(ctx, endpoint, target)
+		if err != nil {
+			log.Errorf("failed lookup for DNS record: %+v", err)
+			return deleted, err
+		}
+
+		if err := c.RemoveDNSRecord(ctx, record); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, record)
 	}
 
-	// Parse the response
-	resp, err := c.doRequest(http.MethodDelete, fmt.Sprintf("ip/dns/static/%s", record.ID), nil)
+	return deleted, nil
+}
+
+// RemoveDNSRecord sends a request to delete a single record by its ID
+func (c *MikrotikApiClient) RemoveDNSRecord(ctx context.Context, record *DNSRecord) error {
+	if !c.ownsRecord(record) {
+		return fmt.Errorf("refusing to delete record %s: %w", record.ID, ErrNotOwned)
+	}
+
+	return c.removeDNSRecord(ctx, record)
+}
+
+// removeDNSRecord sends a request to delete a single record by its ID, whether it is owned by the webhook or not
+func (c *MikrotikApiClient) removeDNSRecord(ctx context.Context, record *DNSRecord) error {
+	if c.dryRun != nil {
+		c.dryRun.record(DryRunOperation{Method: "DELETE", Path: "ip/dns/static/" + record.ID, Record: record})
+		log.Infof("dry run, not deleting record: %s", record.ID)
+		c.forgetRecord(record.ID)
+		return nil
+	}
+
+	// A request sent again after an attempt that failed, but was applied, finds the record already deleted
+	attempts := 0
+	err := c.request(ctx, true, func(ctx context.Context, t transport) error {
+		attempts++
+		err := t.remove(ctx, "ip/dns/static", record.ID)
+		if attempts > 1 && isMissingItemError(err) {
+			log.Infof("record %s was already deleted by an earlier attempt", record.ID)
+			return nil
+		}
+		return err
+	})
 	if err != nil {
 		log.Errorf("error deleting DNS record: %+v", err)
 		return err
 	}
-	defer resp.Body.Close()
 	log.Infof("record deleted: %s", record.ID)
+	c.forgetRecord(record.ID)
 
 	return nil
 }
 
-// lookupDNSRecord searches for a DNS record by key and type
-func (c *MikrotikApiClient) lookupDNSRecord(key, recordType string) (*DNSRecord, error) {
-	log.Debugf("Searching for DNS record: Key: %s, RecordType: %s", key, recordType)
-
-	searchParams := fmt.Sprintf("name=%s", key)
-	if recordType != "A" {
-		searchParams = fmt.Sprintf("%s&type=%s", searchParams, recordType)
-	}
-	log.Debugf("Search params: %s", searchParams)
+// lookupDNSRecord searches for the DNS record of an endpoint pointing to the given target, matching its full identity:
+// name (or regexp), type, match-subdomain and target. An empty target matches any record with the same identity.
+// The records seen by the last GetAllDNSRecords call are searched first, so that their IDs are reused, before querying
+// the router. It fails if more than one record matches, rather than guessing which one is meant.
+func (c *MikrotikApiClient) lookupDNSRecord(ctx context.Context, endpoint *endpoint.Endpoint, target string) (*DNSRecord, error) {
+	log.Debugf("Searching for DNS record: Key: %s, RecordType: %s, Target: %s", endpoint.DNSName, endpoint.RecordType, target)
 
-	// Send the request
-	resp, err := c.doRequest(http.MethodGet, fmt.Sprintf("ip/dns/static?%s", searchParams), nil)
+	wanted, err := newIdentityRecord(endpoint, target)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	compareTarget := target != "" || !hasTarget(endpoint.RecordType)
+
+	if record, err := c.matchOwnedDNSRecord(c.knownRecords(), wanted, compareTarget); record != nil || err != nil {
+		log.Debugf("Found record seen by the last sync: %+v", record)
+		return record, err
+	}
 
-	// Parse the response
-	var record []DNSRecord
-	if err = json.NewDecoder(resp.Body).Decode(&record); err != nil {
-		log.Errorf("Error decoding response body: %v", err)
+	record, err := c.queryDNSRecord(ctx, wanted, compareTarget)
+	if err != nil {
 		return nil, err
 	}
-	if len(record) == 0 {
-		return nil, errors.New("record list is empty")
+	if record == nil {
+		return nil, fmt.Errorf("no record found for %s %s %s: %w", endpoint.DNSName, endpoint.RecordType, target, ErrNotFound)
 	}
 
 	log.Debugf("Found record: %+v", record)
+	return record, nil
+}
 
-	return &record[0], nil
+// findDNSRecord queries the router for the record with the same identity and target as the given one, returning nil
+// if there is none
+func (c *MikrotikApiClient) findDNSRecord(ctx context.Context, record *DNSRecord) (*DNSRecord, error) {
+	return c.queryDNSRecord(ctx, record, true)
 }
 
-// doRequest sends an HTTP request to the MikroTik API with credentials
-func (c *MikrotikApiClient) doRequest(method, path string, body io.Reader) (*http.Response, error) {
-	endpoint_url := fmt.Sprintf("%s/rest/%s", c.BaseUrl, path)
-	log.Debugf("sending %s request to: %s", method, endpoint_url)
+// queryDNSRecord queries the router for the only record with the same identity as the wanted one, returning nil if
+// there is none
+func (c *MikrotikApiClient) queryDNSRecord(ctx context.Context, wanted *DNSRecord, compareTarget bool) (*DNSRecord, error) {
+	// Regexp records have no name, so they are looked up by their pattern instead
+	query := url.Values{"name": {wanted.Name}}
+	if wanted.Name == "" {
+		query = url.Values{"regexp": {wanted.Regexp}}
+	}
+	if recordType := defaultValue(wanted.Type, "A"); recordType != "A" {
+		query.Set("type", recordType)
+	}
+	log.Debugf("Search params: %v", query)
 
-	req, err := http.NewRequest(method, endpoint_url, body)
+	var records []DNSRecord
+	err := c.request(ctx, true, func(ctx context.Context, t transport) error {
+		return t.get(ctx, "ip/dns/static", query, &records)
+	})
 	if err != nil {
-		log.Errorf("failed to create HTTP request: %v", err)
 		return nil, err
 	}
 
-	req.SetBasicAuth(c.Username, c.Password)
+	return c.matchOwnedDNSRecord(records, wanted, compareTarget)
+}
+
+// matchDNSRecord returns the only record with the same identity as the wanted one, or nil if there is none.
+// It fails if more than one record matches.
+func matchDNSRecord(records []DNSRecord, wanted *DNSRecord, compareTarget bool) (*DNSRecord, error) {
+	var matches []DNSRecord
+	for _, record := range records {
+		if record.sameIdentity(wanted, compareTarget) {
+			matches = append(matches, record)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &matches[0], nil
+	default:
+		ids := make([]string, 0, len(matches))
+		for _, match := range matches {
+			ids = append(ids, match.ID)
+		}
+		return nil, fmt.Errorf("ambiguous record %s %s: %d records match (%s), refusing to pick one: %w",
+			defaultValue(wanted.Name, wanted.Regexp), wanted.Type, len(matches), strings.Join(ids, ", "), ErrConflict)
+	}
+}
+
+// knownRecords returns the records seen by the last GetAllDNSRecords call, without the ones deleted since
+func (c *MikrotikApiClient) knownRecords() []DNSRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	resp, err := c.Do(req)
+	return slices.Clone(c.records)
+}
+
+// forgetRecord removes a deleted record from the records seen by the last GetAllDNSRecords call
+func (c *MikrotikApiClient) forgetRecord(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.records = slices.DeleteFunc(c.records, func(record DNSRecord) bool {
+		return record.ID == id
+	})
+}
+
+// lookupDNSForwarder searches for a DNS forwarder by name
+func (c *MikrotikApiClient) lookupDNSForwarder(ctx context.Context, name string) (*MikrotikDNSForwarder, error) {
+	log.Debugf("Searching for DNS forwarder: %s", name)
+
+	var forwarders []MikrotikDNSForwarder
+	err := c.request(ctx, true, func(ctx context.Context, t transport) error {
+		return t.get(ctx, "ip/dns/forwarders", url.Values{"name": {name}}, &forwarders)
+	})
 	if err != nil {
-		log.Errorf("error sending HTTP request: %v", err)
 		return nil, err
 	}
 
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		respBody, _ := io.ReadAll(resp.Body)
-		log.Errorf("request failed with status %s, response: %s", resp.Status, string(respBody))
-		return nil, fmt.Errorf("request failed: %s", resp.Status)
+	for _, forwarder := range forwarders {
+		if forwarder.Name == name {
+			log.Debugf("Found DNS forwarder: %+v", forwarder)
+			return &forwarder, nil
+		}
 	}
-	log.Debugf("request succeeded with status %s", resp.Status)
 
-	return resp, nil
+	return nil, fmt.Errorf("no DNS forwarder named %s is configured: %w", name, ErrNotFound)
+}
+
+// request runs fn against the router, failing over between its API URLs and retrying with backoff.
+// Requests that are not idempotent are only sent again when they could not have been applied.
+func (c *MikrotikApiClient) request(ctx context.Context, idempotent bool, fn func(ctx context.Context, t transport) error) error {
+	return c.requestChecked(ctx, idempotent, nil, fn)
+}
+
+// requestChecked is like request, but a request that is not idempotent is only sent again once applied confirms it
+// was not applied by the failed attempt, as it may have been (i.e. on a timeout or a server error). If applied reports
+// it was, the request succeeds. Without applied, such requests are not retried.
+// Failed requests count towards opening the circuit breaker, while requests it rejects fail without being sent.
+func (c *MikrotikApiClient) requestChecked(
+	ctx context.Context,
+	idempotent bool,
+	applied func(ctx context.Context) (bool, error),
+	fn func(ctx context.Context, t transport) error,
+) error {
+	backoff := c.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		if err := c.breaker.allow(); err != nil {
+			return err
+		}
+
+		err := c.failover(ctx, idempotent, fn)
+		if err == nil {
+			c.breaker.success()
+			return nil
+		}
+		if ctx.Err() != nil {
+			c.breaker.abort()
+			return err
+		}
+		if !isTransientError(err) {
+			// The router answered, it just rejected the request
+			c.breaker.success()
+			return err
+		}
+		c.breaker.failure()
+
+		if !idempotent {
+			if applied == nil {
+				return err
+			}
+			ok, checkErr := applied(ctx)
+			if checkErr != nil {
+				return fmt.Errorf("%w (checking if the request was applied failed: %v)", err, checkErr)
+			}
+			if ok {
+				return nil
+			}
+		}
+
+		if attempt >= c.RetryAttempts {
+			return err
+		}
+
+		delay := jitter(backoff)
+		log.Warnf("request to %s failed, retrying in %v (attempt %d of %d): %v", c.RouterName(), delay, attempt+1, c.RetryAttempts, err)
+		retriesCounter.WithLabelValues(c.RouterName()).Inc()
+		if err := sleep(ctx, delay); err != nil {
+			return fmt.Errorf("request to %s aborted: %w", c.RouterName(), err)
+		}
+		backoff *= 2
+		if c.RetryMaxBackoff > 0 {
+			backoff = min(backoff, c.RetryMaxBackoff)
+		}
+	}
+}
+
+// failover runs fn against the transport of the active API URL, with the request timeout applied to each attempt.
+// If the active API URL is unreachable or fails, fn is run against the next URL of the router instead. Requests that are
+// not idempotent are only sent again when they could not have been applied. Once ctx is done, no further URL is tried.
+func (c *MikrotikApiClient) failover(ctx context.Context, idempotent bool, fn func(ctx context.Context, t transport) error) error {
+	var errs []error
+	for _, index := range c.candidateURLs() {
+		err := c.attempt(ctx, c.transports[index], fn)
+		if err == nil {
+			c.markHealthy(index)
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return fmt.Errorf("request to %s aborted: %w", c.RouterName(), err)
+		}
+		if !isFailoverError(idempotent, err) {
+			return err
+		}
+
+		log.Warnf("API URL %s of %s failed: %v", c.BaseUrls[index], c.RouterName(), err)
+		c.markUnhealthy(index)
+		errs = append(errs, err)
+	}
+
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return fmt.Errorf("all API URLs of %s failed: %w", c.RouterName(), errors.Join(errs...))
+}
+
+// attempt runs fn against a transport, bounded by the request timeout
+func (c *MikrotikApiClient) attempt(ctx context.Context, t transport, fn func(ctx context.Context, t transport) error) error {
+	if c.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.RequestTimeout)
+		defer cancel()
+	}
+	return fn(ctx, t)
+}
+
+// jitter randomizes a backoff delay to between half and all of it, so that retries of concurrent requests are spread out
+func jitter(delay time.Duration) time.Duration {
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// sleep waits for the given delay, or until ctx is done
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// candidateURLs returns the order in which the API URLs should be tried, as indexes in BaseUrls.
+// The active URL comes first, followed by the others in their configured order. URLs that recently failed are only
+// tried as a last resort.
+func (c *MikrotikApiClient) candidateURLs() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	order := []int{c.active}
+	for i := range c.BaseUrls {
+		if i != c.active {
+			order = append(order, i)
+		}
+	}
+
+	var healthy, unhealthy []int
+	for _, index := range order {
+		if failedAt, ok := c.unhealthy[index]; ok && time.Since(failedAt) < unhealthyCooldown {
+			unhealthy = append(unhealthy, index)
+		} else {
+			healthy = append(healthy, index)
+		}
+	}
+
+	return append(healthy, unhealthy...)
+}
+
+// markHealthy makes the given API URL the active one
+func (c *MikrotikApiClient) markHealthy(index int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.unhealthy, index)
+	if c.active == index {
+		return
+	}
+
+	log.Warnf("switching active API URL of %s from %s to %s", c.RouterName(), c.BaseUrls[c.active], c.BaseUrls[index])
+	c.active = index
+	c.reportActiveURL()
+}
+
+// markUnhealthy flags the given API URL as failed, so it is skipped for a while
+func (c *MikrotikApiClient) markUnhealthy(index int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.unhealthy[index] = time.Now()
+}
+
+// ActiveURL returns the API URL currently used to talk to the router
+func (c *MikrotikApiClient) ActiveURL() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.BaseUrls[c.active]
+}
+
+// reportActiveURL updates the metrics with the active API URL. Must be called with the lock held.
+func (c *MikrotikApiClient) reportActiveURL() {
+	for i, baseUrl := range c.BaseUrls {
+		value := 0.0
+		if i == c.active {
+			value = 1
+		}
+		activeURLGauge.WithLabelValues(c.RouterName(), baseUrl).Set(value)
+	}
+}
+
+// isFailoverError checks if a request that failed with the given error should be sent to the next API URL.
+// Connection failures are always retried, since the request never made it. Server errors and other transport errors,
+// like timeouts, are only retried for idempotent requests, as the router may have applied them: a proxy in front of it
+// may answer with a 502 or 504 after the request was forwarded.
+func isFailoverError(idempotent bool, err error) bool {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		return idempotent && reqErr.StatusCode >= 500
+	}
+
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return false
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+
+	return idempotent
+}
+
+// isMissingItemError checks if a request failed because the item it refers to does not exist on the router
+func isMissingItemError(err error) bool {
+	var reqErr *requestError
+	return errors.Is(err, ErrNoSuchItem) || (errors.As(err, &reqErr) && reqErr.StatusCode == http.StatusNotFound)
+}
+
+// isTransientError checks if a request failed because the router could not be reached or failed to process it, as
+// opposed to the router rejecting the request. Such failures may go away when the request is sent again.
+func isTransientError(err error) bool {
+	return isFailoverError(true, err)
+}
+
+// Ready checks if requests can be sent to the router, failing while its circuit breaker is open
+func (c *MikrotikApiClient) Ready() error {
+	if state := c.breaker.State(); state != circuitClosed {
+		return fmt.Errorf("circuit breaker of %s is %s", c.RouterName(), state)
+	}
+	return nil
 }
@@ -2,11 +2,17 @@
 package mikrotik
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"sigs.k8s.io/external-dns/endpoint"
 )
@@ -18,7 +24,7 @@ var (
 
 func TestNewMikrotikClient(t *testing.T) {
 	config := &MikrotikConnectionConfig{
-		BaseUrl:       "https://192.168.88.1:443",
+		BaseUrls:      []string{"https://192.168.88.1:443"},
 		Username:      "admin",
 		Password:      "password",
 		SkipTLSVerify: true,
@@ -42,11 +48,16 @@ func TestNewMikrotikClient(t *testing.T) {
 		t.Errorf("Expected defaults to be %v, got %v", defaults, client.MikrotikDefaults)
 	}
 
-	if client.Client == nil {
-		t.Errorf("Expected HTTP client to be initialized")
+	if len(client.transports) != 1 {
+		t.Fatalf("Expected 1 transport, got %d", len(client.transports))
 	}
 
-	transport, ok := client.Transport.(*http.Transport)
+	rest, ok := client.transports[0].(*instrumentedTransport).transport.(*restTransport)
+	if !ok {
+		t.Fatalf("Expected transport to be *restTransport")
+	}
+
+	transport, ok := rest.client.Transport.(*http.Transport)
 	if !ok {
 		t.Errorf("Expected Transport to be *http.Transport")
 	}
@@ -58,6 +69,570 @@ func TestNewMikrotikClient(t *testing.T) {
 	}
 }
 
+func TestReadConnectionConfigs(t *testing.T) {
+	testCases := []struct {
+		name          string
+		environ       []string
+		expected      []*MikrotikConnectionConfig
+		expectedError bool
+	}{
+		{
+			name: "Single router",
+			environ: []string{
+				"MIKROTIK_BASEURL=https://192.168.88.1:443",
+				"MIKROTIK_USERNAME=admin",
+				"MIKROTIK_PASSWORD=password",
+				"MIKROTIK_DEFAULT_TTL=60",
+			},
+			expected: []*MikrotikConnectionConfig{
+				{BaseUrls: []string{"https://192.168.88.1:443"}, Username: "admin", Password: "password", Transport: "rest", ConnectTimeout: 10 * time.Second, RequestTimeout: 30 * time.Second},
+			},
+		},
+		{
+			name: "Multiple routers with shared credentials",
+			environ: []string{
+				"MIKROTIK_USERNAME=admin",
+				"MIKROTIK_PASSWORD=password",
+				"MIKROTIK_SKIP_TLS_VERIFY=true",
+				"MIKROTIK_2_BASEURL=https://192.168.88.2:443",
+				"MIKROTIK_2_PASSWORD=other",
+				"MIKROTIK_1_NAME=primary",
+				"MIKROTIK_1_BASEURL=https://192.168.88.1:443",
+			},
+			expected: []*MikrotikConnectionConfig{
+				{Name: "primary", BaseUrls: []string{"https://192.168.88.1:443"}, Username: "admin", Password: "password", SkipTLSVerify: true, Transport: "rest", ConnectTimeout: 10 * time.Second, RequestTimeout: 30 * time.Second},
+				{BaseUrls: []string{"https://192.168.88.2:443"}, Username: "admin", Password: "other", SkipTLSVerify: true, Transport: "rest", ConnectTimeout: 10 * time.Second, RequestTimeout: 30 * time.Second},
+			},
+		},
+		{
+			name: "Router with several API URLs",
+			environ: []string{
+				"MIKROTIK_BASEURL=https://192.168.88.1:443,https://10.0.0.1:443",
+				"MIKROTIK_USERNAME=admin",
+				"MIKROTIK_PASSWORD=password",
+			},
+			expected: []*MikrotikConnectionConfig{
+				{BaseUrls: []string{"https://192.168.88.1:443", "https://10.0.0.1:443"}, Username: "admin", Password: "password", Transport: "rest", ConnectTimeout: 10 * time.Second, RequestTimeout: 30 * time.Second},
+			},
+		},
+		{
+			name: "Router with custom timeouts",
+			environ: []string{
+				"MIKROTIK_BASEURL=https://192.168.88.1:443",
+				"MIKROTIK_USERNAME=admin",
+				"MIKROTIK_PASSWORD=password",
+				"MIKROTIK_CONNECT_TIMEOUT=2s",
+				"MIKROTIK_REQUEST_TIMEOUT=1m",
+			},
+			expected: []*MikrotikConnectionConfig{
+				{BaseUrls: []string{"https://192.168.88.1:443"}, Username: "admin", Password: "password", Transport: "rest", ConnectTimeout: 2 * time.Second, RequestTimeout: time.Minute},
+			},
+		},
+		{
+			name: "Router missing required settings",
+			environ: []string{
+				"MIKROTIK_1_BASEURL=https://192.168.88.1:443",
+				"MIKROTIK_1_USERNAME=admin",
+				"MIKROTIK_1_PASSWORD=password",
+				"MIKROTIK_2_BASEURL=https://192.168.88.2:443",
+			},
+			expectedError: true,
+		},
+		{
+			name:          "No router",
+			environ:       []string{},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			configs, err := ReadConnectionConfigs(tc.environ)
+
+			if tc.expectedError {
+				if err == nil {
+					t.Fatalf("Expected error, got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(configs) != len(tc.expected) {
+				t.Fatalf("Expected %d configs, got %d", len(tc.expected), len(configs))
+			}
+			for i := range tc.expected {
+				// the retry and circuit breaker settings are left to their defaults in all cases
+				expected := *tc.expected[i]
+				expected.RetryAttempts, expected.RetryBackoff, expected.RetryMaxBackoff = 3, 500*time.Millisecond, 10*time.Second
+				expected.CircuitBreakerThreshold, expected.CircuitBreakerCooldown = 5, 30*time.Second
+
+				if !reflect.DeepEqual(*configs[i], expected) {
+					t.Errorf("Expected config %+v, got %+v", expected, *configs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFailover(t *testing.T) {
+	mockServerInfo := MikrotikSystemInfo{BoardName: "RB5009UG+S+", Version: "7.16 (stable)"}
+
+	var primaryHits, secondaryHits int
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryHits++
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}))
+	defer primary.Close()
+
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secondaryHits++
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(mockServerInfo)
+	}))
+	defer secondary.Close()
+
+	// an address nothing listens on, to simulate an unreachable router
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	client, err := NewMikrotikClient(&MikrotikConnectionConfig{
+		BaseUrls: []string{unreachable.URL, primary.URL, secondary.URL},
+		Username: "testuser",
+		Password: "testpass",
+	}, &MikrotikDefaults{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	info, err := client.GetSystemInfo(context.Background())
+	if err != nil {
+		t.Fatalf("Expected failover to succeed, got %v", err)
+	}
+	if info.BoardName != mockServerInfo.BoardName {
+		t.Errorf("Expected board name %s, got %s", mockServerInfo.BoardName, info.BoardName)
+	}
+	if client.ActiveURL() != secondary.URL {
+		t.Errorf("Expected active URL %s, got %s", secondary.URL, client.ActiveURL())
+	}
+
+	// the healthy URL should now be used directly
+	if _, err := client.GetSystemInfo(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if primaryHits != 1 || secondaryHits != 2 {
+		t.Errorf("Expected 1 request to the failing URL and 2 to the healthy one, got %d and %d", primaryHits, secondaryHits)
+	}
+}
+
+func TestFailoverAllURLsFailing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client, err := NewMikrotikClient(&MikrotikConnectionConfig{
+		BaseUrls: []string{server.URL, server.URL + "/"},
+	}, &MikrotikDefaults{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	if _, err := client.GetSystemInfo(context.Background()); err == nil {
+		t.Fatalf("Expected error, got none")
+	}
+	if client.ActiveURL() != server.URL {
+		t.Errorf("Expected active URL to stay %s, got %s", server.URL, client.ActiveURL())
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client, err := NewMikrotikClient(&MikrotikConnectionConfig{
+		BaseUrls:       []string{server.URL},
+		RequestTimeout: 50 * time.Millisecond,
+	}, &MikrotikDefaults{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	start := time.Now()
+	_, err = client.GetSystemInfo(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline exceeded error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the request to time out quickly, took %v", elapsed)
+	}
+}
+
+func TestRequestCancelled(t *testing.T) {
+	received := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(received)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client, err := NewMikrotikClient(&MikrotikConnectionConfig{
+		BaseUrls: []string{server.URL, server.URL + "/"},
+	}, &MikrotikDefaults{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-received
+		cancel()
+	}()
+
+	_, err = client.GetSystemInfo(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected cancellation error, got %v", err)
+	}
+	// a cancelled request must not fail over to the other URLs or mark the router unhealthy
+	if client.ActiveURL() != server.URL {
+		t.Errorf("Expected active URL to stay %s, got %s", server.URL, client.ActiveURL())
+	}
+}
+
+func TestRetry(t *testing.T) {
+	testCases := []struct {
+		name          string
+		statuses      []int
+		expectedHits  int
+		expectedError bool
+	}{
+		{name: "Success after server errors", statuses: []int{503, 500, 200}, expectedHits: 3},
+		{name: "Server errors on all attempts", statuses: []int{503, 503, 503, 200}, expectedHits: 3, expectedError: true},
+		{name: "Client error is not retried", statuses: []int{401, 200}, expectedHits: 1, expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hits := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tc.statuses[hits]
+				hits++
+				if status != http.StatusOK {
+					http.Error(w, http.StatusText(status), status)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(MikrotikSystemInfo{BoardName: "RB5009UG+S+"})
+			}))
+			defer server.Close()
+
+			client, err := NewMikrotikClient(&MikrotikConnectionConfig{
+				BaseUrls:      []string{server.URL},
+				RetryAttempts: 3,
+				RetryBackoff:  time.Millisecond,
+			}, &MikrotikDefaults{})
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+
+			_, err = client.GetSystemInfo(context.Background())
+			if tc.expectedError && err == nil {
+				t.Errorf("Expected error, got none")
+			}
+			if !tc.expectedError && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if hits != tc.expectedHits {
+				t.Errorf("Expected %d requests, got %d", tc.expectedHits, hits)
+			}
+		})
+	}
+}
+
+func TestRetryCreate(t *testing.T) {
+	testCases := []struct {
+		name         string
+		applied      bool
+		expectedPuts int
+		expectedID   string
+	}{
+		{name: "Failed create that was not applied is sent again", applied: false, expectedPuts: 2, expectedID: "*2"},
+		{name: "Failed create that was applied is adopted", applied: true, expectedPuts: 1, expectedID: "*1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var records []DNSRecord
+			puts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.Method {
+				case http.MethodGet:
+					w.Header().Set("Content-Type", "application/json")
+					_ = json.NewEncoder(w).Encode(records)
+				case http.MethodPut:
+					puts++
+					var record DNSRecord
+					_ = json.NewDecoder(r.Body).Decode(&record)
+					record.ID = fmt.Sprintf("*%d", puts)
+
+					// the first request fails, whether or not the router applied it
+					if puts == 1 {
+						if tc.applied {
+							records = append(records, record)
+						}
+						http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+						return
+					}
+					records = append(records, record)
+					w.Header().Set("Content-Type", "application/json")
+					_ = json.NewEncoder(w).Encode(record)
+				}
+			}))
+			defer server.Close()
+
+			client, err := NewMikrotikClient(&MikrotikConnectionConfig{
+				BaseUrls:      []string{server.URL},
+				RetryAttempts: 3,
+				RetryBackoff:  time.Millisecond,
+			}, &MikrotikDefaults{})
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+
+			created, err := client.CreateDNSRecord(context.Background(), endpoint.NewEndpoint("example.com", "A", "192.0.2.1"))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if puts != tc.expectedPuts {
+				t.Errorf("Expected %d create requests, got %d", tc.expectedPuts, puts)
+			}
+			if len(created) != 1 || created[0].ID != tc.expectedID {
+				t.Errorf("Expected record %s to be returned, got %+v", tc.expectedID, created)
+			}
+			if len(records) != 1 {
+				t.Errorf("Expected a single record on the router, got %+v", records)
+			}
+		})
+	}
+}
+
+func TestRetryDelete(t *testing.T) {
+	deletes := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deletes++
+		// the first request is applied, but times out behind a proxy
+		if deletes == 1 {
+			http.Error(w, "Gateway Timeout", http.StatusGatewayTimeout)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":404,"message":"Not Found","detail":"no such item"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewMikrotikClient(&MikrotikConnectionConfig{
+		BaseUrls:      []string{server.URL},
+		RetryAttempts: 3,
+		RetryBackoff:  time.Millisecond,
+	}, &MikrotikDefaults{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	if err := client.RemoveDNSRecord(context.Background(), &DNSRecord{ID: "*1", Name: "example.com", Address: "192.0.2.1"}); err != nil {
+		t.Errorf("Expected the record deleted by the first attempt to be deleted, got %v", err)
+	}
+	if deletes != 2 {
+		t.Errorf("Expected 2 delete requests, got %d", deletes)
+	}
+
+	// a record missing on the first attempt is still reported
+	deletes = 1
+	if err := client.removeDNSRecord(context.Background(), &DNSRecord{ID: "*2"}); !errors.Is(err, ErrNoSuchItem) {
+		t.Errorf("Expected a missing item error, got %v", err)
+	}
+}
+
+func TestRequestErrorBody(t *testing.T) {
+	testCases := []struct {
+		name           string
+		body           string
+		expectedDetail string
+		expectedErr    error
+	}{
+		{name: "Existing entry", body: `{"error":400,"message":"Bad Request","detail":"failure: entry already exists"}`, expectedDetail: "failure: entry already exists", expectedErr: ErrAlreadyExists},
+		{name: "Missing entry", body: `{"error":404,"message":"Not Found","detail":"no such item"}`, expectedDetail: "no such item", expectedErr: ErrNoSuchItem},
+		{name: "Missing permissions", body: `{"error":400,"message":"Bad Request","detail":"not enough permissions (9)"}`, expectedDetail: "not enough permissions (9)", expectedErr: ErrPermissionDenied},
+		{name: "Unknown detail", body: `{"error":400,"message":"Bad Request","detail":"invalid value for argument address"}`, expectedDetail: "invalid value for argument address"},
+		{name: "Not a RouterOS error", body: "Bad Request"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = fmt.Fprint(w, tc.body)
+			}))
+			defer server.Close()
+
+			transport, err := newRestTransport(server.URL, &MikrotikConnectionConfig{})
+			if err != nil {
+				t.Fatalf("Failed to create transport: %v", err)
+			}
+
+			err = transport.add(context.Background(), "ip/dns/static", &DNSRecord{Name: "example.com"}, &DNSRecord{})
+			var reqErr *requestError
+			if !errors.As(err, &reqErr) {
+				t.Fatalf("Expected a *requestError, got %v", err)
+			}
+			if reqErr.StatusCode != http.StatusBadRequest || reqErr.Detail != tc.expectedDetail {
+				t.Errorf("Expected status 400 and detail %q, got %d and %q", tc.expectedDetail, reqErr.StatusCode, reqErr.Detail)
+			}
+			if tc.expectedErr != nil && !errors.Is(err, tc.expectedErr) {
+				t.Errorf("Expected the error to wrap %v, got %v", tc.expectedErr, err)
+			}
+			if tc.expectedErr == nil && errors.Unwrap(err) != nil {
+				t.Errorf("Expected the error to wrap nothing, got %v", errors.Unwrap(err))
+			}
+		})
+	}
+}
+
+func TestCreateExistingRecord(t *testing.T) {
+	testCases := []struct {
+		name          string
+		comment       string
+		expectedError bool
+	}{
+		{name: "Identical entry is adopted", comment: ""},
+		{name: "Entry with other properties is not adopted", comment: "created by hand", expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var records []DNSRecord
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.Method {
+				case http.MethodGet:
+					w.Header().Set("Content-Type", "application/json")
+					_ = json.NewEncoder(w).Encode(records)
+				case http.MethodPut:
+					// the entry already exists on the router, possibly with other properties
+					var record DNSRecord
+					_ = json.NewDecoder(r.Body).Decode(&record)
+					record.ID = "*1"
+					record.Comment = tc.comment
+					records = append(records, record)
+
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusBadRequest)
+					_, _ = fmt.Fprint(w, `{"error":400,"message":"Bad Request","detail":"failure: entry already exists"}`)
+				}
+			}))
+			defer server.Close()
+
+			client, err := NewMikrotikClient(&MikrotikConnectionConfig{BaseUrls: []string{server.URL}}, &MikrotikDefaults{})
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+
+			created, err := client.CreateDNSRecord(context.Background(), endpoint.NewEndpoint("example.com", "A", "192.0.2.1"))
+			if tc.expectedError {
+				if !errors.Is(err, ErrAlreadyExists) {
+					t.Errorf("Expected an error wrapping %v, got %v", ErrAlreadyExists, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			// adopted entries were not created, so they are not removed when the changes are rolled back
+			if len(created) != 0 {
+				t.Errorf("Expected no created records, got %+v", created)
+			}
+		})
+	}
+}
+
+func TestRequestCircuitBreaker(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client, err := NewMikrotikClient(&MikrotikConnectionConfig{
+		BaseUrls:                []string{server.URL},
+		RetryAttempts:           2,
+		RetryBackoff:            time.Millisecond,
+		CircuitBreakerThreshold: 3,
+		CircuitBreakerCooldown:  time.Minute,
+	}, &MikrotikDefaults{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if err := client.Ready(); err != nil {
+		t.Fatalf("Expected client to be ready, got %v", err)
+	}
+
+	// the first request fails twice, the second one opens the circuit on its first attempt
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetSystemInfo(context.Background()); err == nil {
+			t.Fatalf("Expected error, got none")
+		}
+	}
+	if hits != 3 {
+		t.Errorf("Expected 3 requests before the circuit opened, got %d", hits)
+	}
+
+	if _, err := client.GetSystemInfo(context.Background()); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected circuit open error, got %v", err)
+	}
+	if hits != 3 {
+		t.Errorf("Expected no request while the circuit is open, got %d", hits-3)
+	}
+	if err := client.Ready(); err == nil {
+		t.Errorf("Expected client not to be ready while the circuit is open")
+	}
+}
+
+func TestIsFailoverError(t *testing.T) {
+	testCases := []struct {
+		name       string
+		idempotent bool
+		err        error
+		expected   bool
+	}{
+		{name: "Server error on idempotent request", idempotent: true, err: &requestError{StatusCode: 500}, expected: true},
+		{name: "Server error on non-idempotent request", idempotent: false, err: &requestError{StatusCode: 502}, expected: false},
+		{name: "Client error", idempotent: true, err: &requestError{StatusCode: 400}, expected: false},
+		{name: "API trap", idempotent: true, err: &apiError{Message: "no such item"}, expected: false},
+		{name: "Connection refused", idempotent: false, err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, expected: true},
+		{name: "Timeout on idempotent request", idempotent: true, err: &net.OpError{Op: "read", Err: errors.New("i/o timeout")}, expected: true},
+		{name: "Timeout on non-idempotent request", idempotent: false, err: &net.OpError{Op: "read", Err: errors.New("i/o timeout")}, expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isFailoverError(tc.idempotent, tc.err); got != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
 func TestGetSystemInfo(t *testing.T) {
 	mockServerInfo := MikrotikSystemInfo{
 		ArchitectureName:     "arm64",
@@ -118,7 +693,7 @@ func TestGetSystemInfo(t *testing.T) {
 		{
 			name: "Valid credentials",
 			config: MikrotikConnectionConfig{
-				BaseUrl:       server.URL,
+				BaseUrls:      []string{server.URL},
 				Username:      mockUsername,
 				Password:      mockPassword,
 				SkipTLSVerify: true,
@@ -129,7 +704,7 @@ func TestGetSystemInfo(t *testing.T) {
 		{
 			name: "Incorrect password",
 			config: MikrotikConnectionConfig{
-				BaseUrl:       server.URL,
+				BaseUrls:      []string{server.URL},
 				Username:      mockUsername,
 				Password:      "wrongpass",
 				SkipTLSVerify: true,
@@ -140,7 +715,7 @@ func TestGetSystemInfo(t *testing.T) {
 		{
 			name: "Incorrect username",
 			config: MikrotikConnectionConfig{
-				BaseUrl:       server.URL,
+				BaseUrls:      []string{server.URL},
 				Username:      "wronguser",
 				Password:      mockPassword,
 				SkipTLSVerify: true,
@@ -151,7 +726,7 @@ func TestGetSystemInfo(t *testing.T) {
 		{
 			name: "Incorrect username and password",
 			config: MikrotikConnectionConfig{
-				BaseUrl:       server.URL,
+				BaseUrls:      []string{server.URL},
 				Username:      "wronguser",
 				Password:      "wrongpass",
 				SkipTLSVerify: true,
@@ -162,7 +737,7 @@ func TestGetSystemInfo(t *testing.T) {
 		{
 			name: "Missing credentials",
 			config: MikrotikConnectionConfig{
-				BaseUrl:       server.URL,
+				BaseUrls:      []string{server.URL},
 				Username:      "",
 				Password:      "",
 				SkipTLSVerify: true,
@@ -182,7 +757,7 @@ func TestGetSystemInfo(t *testing.T) {
 			if err != nil {
 				t.Fatalf("Failed to create client: %v", err)
 			}
-			info, err := client.GetSystemInfo()
+			info, err := client.GetSystemInfo(context.Background())
 
 			if tc.expectedError {
 				if err == nil {
@@ -407,7 +982,7 @@ func TestCreateDNSRecord(t *testing.T) {
 
 			// Set up the client with correct credentials
 			config := &MikrotikConnectionConfig{
-				BaseUrl:       server.URL,
+				BaseUrls:      []string{server.URL},
 				Username:      mockUsername,
 				Password:      mockPassword,
 				SkipTLSVerify: true,
@@ -417,7 +992,7 @@ func TestCreateDNSRecord(t *testing.T) {
 			if err != nil {
 				t.Fatalf("Failed to create client: %v", err)
 			}
-			record, err := client.CreateDNSRecord(tc.endpoint)
+			records, err := client.CreateDNSRecord(context.Background(), tc.endpoint)
 
 			if tc.expectedError {
 				if err == nil {
@@ -438,8 +1013,11 @@ func TestCreateDNSRecord(t *testing.T) {
 			}
 
 			// Verify that the client received the correct record
-			if record.ID != storedRecord.ID {
-				t.Errorf("Expected ID '%s', got '%s'", storedRecord.ID, record.ID)
+			if len(records) != 1 {
+				t.Fatalf("Expected 1 record, got %d", len(records))
+			}
+			if records[0].ID != storedRecord.ID {
+				t.Errorf("Expected ID '%s', got '%s'", storedRecord.ID, records[0].ID)
 			}
 
 			// Additional checks specific to record type
@@ -463,6 +1041,94 @@ func TestCreateDNSRecord(t *testing.T) {
 	}
 }
 
+func TestCreateFWDRecord(t *testing.T) {
+	testCases := []struct {
+		name          string
+		forwardTo     string
+		expectedError bool
+	}{
+		{
+			name:          "Forward to IP address",
+			forwardTo:     "10.0.0.53",
+			expectedError: false,
+		},
+		{
+			name:          "Forward to configured forwarder",
+			forwardTo:     "ad-dns",
+			expectedError: false,
+		},
+		{
+			name:          "Forward to unknown forwarder",
+			forwardTo:     "unknown-dns",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var created []DNSRecord
+
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/rest/ip/dns/forwarders" && r.Method == http.MethodGet {
+					forwarders := []MikrotikDNSForwarder{}
+					if r.URL.Query().Get("name") == "ad-dns" {
+						forwarders = append(forwarders, MikrotikDNSForwarder{ID: "*1", Name: "ad-dns", DnsServers: "10.0.0.53,10.0.0.54"})
+					}
+					w.Header().Set("Content-Type", "application/json")
+					if err := json.NewEncoder(w).Encode(forwarders); err != nil {
+						t.Errorf("error json encoding dns forwarders")
+					}
+					return
+				}
+
+				if r.URL.Path == "/rest/ip/dns/static" && r.Method == http.MethodPut {
+					var record DNSRecord
+					if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+						http.Error(w, "Bad Request", http.StatusBadRequest)
+						return
+					}
+					record.ID = "*NEW"
+					created = append(created, record)
+
+					w.Header().Set("Content-Type", "application/json")
+					if err := json.NewEncoder(w).Encode(record); err != nil {
+						t.Errorf("error json encoding dns record")
+					}
+					return
+				}
+
+				http.NotFound(w, r)
+			}))
+			defer server.Close()
+
+			client, err := NewMikrotikClient(&MikrotikConnectionConfig{BaseUrls: []string{server.URL}, SkipTLSVerify: true}, &MikrotikDefaults{})
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+
+			ep := endpoint.NewEndpoint("corp.example.com", "FWD", tc.forwardTo).WithProviderSpecific("match-subdomain", "true")
+			_, err = client.CreateDNSRecord(context.Background(), ep)
+
+			if tc.expectedError {
+				if err == nil {
+					t.Fatalf("Expected error, got none")
+				}
+				if len(created) != 0 {
+					t.Fatalf("Expected no record to be created, got %v", created)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(created) != 1 || created[0].ForwardTo != tc.forwardTo || created[0].MatchSubdomain != "true" {
+				t.Fatalf("Expected FWD record to %s with match-subdomain, got %v", tc.forwardTo, created)
+			}
+		})
+	}
+}
+
 func TestDeleteDNSRecord(t *testing.T) {
 	testCases := []struct {
 		name           string
@@ -621,7 +1287,7 @@ func TestDeleteDNSRecord(t *testing.T) {
 			defer server.Close()
 
 			config := &MikrotikConnectionConfig{
-				BaseUrl:       server.URL,
+				BaseUrls:      []string{server.URL},
 				Username:      mockUsername,
 				Password:      mockPassword,
 				SkipTLSVerify: true,
@@ -632,7 +1298,7 @@ func TestDeleteDNSRecord(t *testing.T) {
 				t.Fatalf("Failed to create client: %v", err)
 			}
 
-			err = client.DeleteDNSRecord(tc.endpoint)
+			_, err = client.DeleteDNSRecord(context.Background(), tc.endpoint)
 
 			if tc.expectedError {
 				if err == nil {
@@ -651,6 +1317,157 @@ func TestDeleteDNSRecord(t *testing.T) {
 	}
 }
 
+func TestDeleteDNSRecordTargets(t *testing.T) {
+	recordStore := []DNSRecord{
+		{ID: "*1", Name: "rr.example.com", Address: "192.0.2.1"},
+		{ID: "*2", Name: "rr.example.com", Address: "192.0.2.2"},
+		{ID: "*3", Name: "rr.example.com", Address: "192.0.2.3"},
+		{ID: "*4", Regexp: ".*\\.example\\.com", Address: "192.0.2.2"},
+	}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == "/rest/ip/dns/static" {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(recordStore); err != nil {
+				t.Errorf("error json encoding dns records")
+			}
+			return
+		}
+
+		if r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/rest/ip/dns/static/") {
+			id := strings.TrimPrefix(r.URL.Path, "/rest/ip/dns/static/")
+			for i, record := range recordStore {
+				if record.ID == id {
+					recordStore = append(recordStore[:i], recordStore[i+1:]...)
+					w.WriteHeader(http.StatusOK)
+					return
+				}
+			}
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	client, err := NewMikrotikClient(&MikrotikConnectionConfig{BaseUrls: []string{server.URL}, SkipTLSVerify: true}, &MikrotikDefaults{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	_, err = client.DeleteDNSRecord(context.Background(), endpoint.NewEndpoint("", "A", "192.0.2.2").WithProviderSpecific("regexp", ".*\\.example\\.com"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(recordStore) != 3 || recordStore[1].ID != "*2" {
+		t.Fatalf("Expected only the regexp record *4 to be deleted, got %v", recordStore)
+	}
+
+	deleted, err := client.DeleteDNSRecord(context.Background(), endpoint.NewEndpoint("rr.example.com", "A", "192.0.2.3", "192.0.2.1"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(deleted) != 2 || deleted[0].Address != "192.0.2.3" || deleted[1].Address != "192.0.2.1" {
+		t.Errorf("Expected the deleted records to be returned, got %v", deleted)
+	}
+	if len(recordStore) != 1 || recordStore[0].ID != "*2" {
+		t.Fatalf("Expected only record *2 to be left, got %v", recordStore)
+	}
+
+	_, err = client.DeleteDNSRecord(context.Background(), endpoint.NewEndpoint("", "A", "192.0.2.2").WithProviderSpecific("regexp", ".*\\.example\\.com"))
+	if err == nil {
+		t.Fatalf("Expected error deleting a regexp record that does not exist, got none")
+	}
+
+	_, err = client.DeleteDNSRecord(context.Background(), endpoint.NewEndpoint("rr.example.com", "A", "192.0.2.4"))
+	if err == nil {
+		t.Fatalf("Expected error deleting a target that does not exist, got none")
+	}
+	if len(recordStore) != 1 {
+		t.Fatalf("Expected record *2 to be left untouched, got %v", recordStore)
+	}
+}
+
+func TestLookupDNSRecord(t *testing.T) {
+	records := []DNSRecord{
+		{ID: "*1", Name: "x.example.com", Address: "192.0.2.1"},
+		{ID: "*2", Name: "x.example.com", Type: "CNAME", CName: "y.example.com"},
+		{ID: "*3", Name: "x.example.com", Type: "A", Address: "192.0.2.1", MatchSubdomain: "true"},
+		{ID: "*4", Name: "dup.example.com", Type: "A", Address: "192.0.2.9"},
+		{ID: "*5", Name: "dup.example.com", Type: "A", Address: "192.0.2.9"},
+		{ID: "*6", Regexp: ".*\\.example\\.com", Type: "A", Address: "192.0.2.1"},
+	}
+
+	lookups := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == "/rest/ip/dns/static" {
+			if r.URL.Query().Get("name") != "" || r.URL.Query().Get("regexp") != "" {
+				lookups++
+			}
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(records); err != nil {
+				t.Errorf("error json encoding dns records")
+			}
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name          string
+		endpoint      *endpoint.Endpoint
+		target        string
+		expectedID    string
+		expectedError bool
+	}{
+		{name: "A record without type", endpoint: endpoint.NewEndpoint("x.example.com", "A"), target: "192.0.2.1", expectedID: "*1"},
+		{name: "Match subdomain", endpoint: endpoint.NewEndpoint("x.example.com", "A").WithProviderSpecific("match-subdomain", "true"), target: "192.0.2.1", expectedID: "*3"},
+		{name: "Other type with the same name", endpoint: endpoint.NewEndpoint("x.example.com", "CNAME"), target: "y.example.com", expectedID: "*2"},
+		{name: "Without target", endpoint: endpoint.NewEndpoint("X.example.com", "A"), expectedID: "*1"},
+		{name: "Regexp record", endpoint: endpoint.NewEndpoint("", "A").WithProviderSpecific("regexp", ".*\\.example\\.com"), target: "192.0.2.1", expectedID: "*6"},
+		{name: "Ambiguous record", endpoint: endpoint.NewEndpoint("dup.example.com", "A"), target: "192.0.2.9", expectedError: true},
+		{name: "Missing record", endpoint: endpoint.NewEndpoint("x.example.com", "TXT"), target: "text", expectedError: true},
+	}
+
+	for _, cached := range []bool{false, true} {
+		client, err := NewMikrotikClient(&MikrotikConnectionConfig{BaseUrls: []string{server.URL}, SkipTLSVerify: true}, &MikrotikDefaults{})
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		if cached {
+			if _, err := client.GetAllDNSRecords(context.Background()); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+		}
+
+		for _, tc := range testCases {
+			t.Run(fmt.Sprintf("%s (cached: %v)", tc.name, cached), func(t *testing.T) {
+				record, err := client.lookupDNSRecord(context.Background(), tc.endpoint, tc.target)
+				if tc.expectedError {
+					if err == nil {
+						t.Fatalf("Expected error, got record %+v", record)
+					}
+					return
+				}
+
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				if record.ID != tc.expectedID {
+					t.Errorf("Expected record %s, got %s", tc.expectedID, record.ID)
+				}
+			})
+		}
+	}
+
+	// Every lookup queries the router, unless the records were fetched before, where only the missing record does
+	if lookups != len(testCases)+1 {
+		t.Errorf("Expected %d lookups on the router, got %d", len(testCases)+1, lookups)
+	}
+}
+
 func TestGetAllDNSRecords(t *testing.T) {
 	testCases := []struct {
 		name         string
@@ -736,7 +1553,7 @@ func TestGetAllDNSRecords(t *testing.T) {
 
 			// Set up the client
 			config := &MikrotikConnectionConfig{
-				BaseUrl:       server.URL,
+				BaseUrls:      []string{server.URL},
 				Username:      mockUsername,
 				Password:      mockPassword,
 				SkipTLSVerify: true,
@@ -746,7 +1563,7 @@ func TestGetAllDNSRecords(t *testing.T) {
 			if err != nil {
 				t.Fatalf("Failed to create client: %v", err)
 			}
-			records, err := client.GetAllDNSRecords()
+			records, err := client.GetAllDNSRecords(context.Background())
 
 			if tc.expectError {
 				if err == nil {
@@ -0,0 +1,123 @@
+package mikrotik
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+
+	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// commentData is the data the default comment template is rendered with, i.e. {{.Labels.resource}} ({{.RecordType}})
+type commentData struct {
+	DNSName    string
+	RecordType string
+	TTL        int64
+	Targets    endpoint.Targets
+	Labels     endpoint.Labels
+
+	// ProviderSpecific holds the provider-specific properties of the endpoint, without the webhook/ prefix
+	ProviderSpecific map[string]string
+}
+
+// commentTemplates caches the parsed comment templates by their text
+var commentTemplates sync.Map
+
+// parseCommentTemplate parses the text of a comment template. Missing labels and properties render as empty strings.
+func parseCommentTemplate(text string) (*template.Template, error) {
+	if cached, ok := commentTemplates.Load(text); ok {
+		return cached.(*template.Template), nil
+	}
+
+	tmpl, err := template.New("comment").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid comment template %q: %w", text, err)
+	}
+	commentTemplates.Store(text, tmpl)
+	return tmpl, nil
+}
+
+// renderComment renders the comment template with the data of the endpoint, and sanitizes the result for RouterOS
+func renderComment(text string, ep *endpoint.Endpoint, maxLength int) (string, error) {
+	tmpl, err := parseCommentTemplate(text)
+	if err != nil {
+		return "", err
+	}
+
+	data := commentData{
+		DNSName:          strings.TrimSuffix(ep.DNSName, "."),
+		RecordType:       ep.RecordType,
+		TTL:              int64(ep.RecordTTL),
+		Targets:          ep.Targets,
+		Labels:           endpoint.NewLabels(),
+		ProviderSpecific: map[string]string{},
+	}
+	for key, value := range ep.Labels {
+		data.Labels[key] = value
+	}
+	for _, property := range ep.ProviderSpecific {
+		name := strings.TrimPrefix(property.Name, "webhook/")
+		// properties without the prefix take precedence, like in getProviderSpecificOrDefault
+		if _, ok := data.ProviderSpecific[name]; !ok || name == property.Name {
+			data.ProviderSpecific[name] = property.Value
+		}
+	}
+
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return "", fmt.Errorf("rendering comment template %q failed: %w", text, err)
+	}
+	return sanitizeComment(rendered.String(), maxLength), nil
+}
+
+// sanitizeComment makes a comment safe to store on a router: line breaks, tabs and other control characters are
+// replaced by spaces, runs of spaces are collapsed, and the comment is cut to at most maxLength bytes (0 for no limit)
+// without splitting a character.
+func sanitizeComment(comment string, maxLength int) string {
+	fields := strings.FieldsFunc(comment, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r) || r == utf8.RuneError
+	})
+	comment = strings.Join(fields, " ")
+
+	if maxLength <= 0 || len(comment) <= maxLength {
+		return comment
+	}
+	cut := maxLength
+	for cut > 0 && !utf8.RuneStart(comment[cut]) {
+		cut--
+	}
+	return strings.TrimRight(comment[:cut], " ")
+}
+
+// defaultComment returns the default comment of an endpoint, rendered from the comment template of its domain or the
+// MIKROTIK_DEFAULT_COMMENT template, or an empty string if there is none
+func (p *MikrotikProvider) defaultComment(ep *endpoint.Endpoint) string {
+	if p.defaults == nil {
+		return ""
+	}
+	text := p.defaultProperty(ep, "comment", p.defaults.DefaultComment)
+	if text == "" {
+		return ""
+	}
+
+	comment, err := renderComment(text, ep, p.defaults.CommentMaxLength)
+	if err != nil {
+		log.Warnf("Failed to render the default comment of endpoint %v: %v", ep, err)
+		return ""
+	}
+	return comment
+}
+
+// isDefaultComment checks if a comment is the default comment of either of the endpoints being compared. Comments
+// rendered from a template depend on the endpoint they were rendered for, and the endpoints read from a router do not
+// carry all of the data of the desired ones (i.e. their labels), so both renderings are accepted.
+func (p *MikrotikProvider) isDefaultComment(comment string, a, b *endpoint.Endpoint) bool {
+	if comment == "" {
+		return true
+	}
+	return comment == p.defaultComment(a) || comment == p.defaultComment(b)
+}
@@ -0,0 +1,101 @@
+package mikrotik
+
+import (
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestRenderComment(t *testing.T) {
+	ep := endpoint.NewEndpointWithTTL("web.example.com.", "A", 300, "192.0.2.1").
+		WithLabel("resource", "ingress/default/web").
+		WithProviderSpecific("webhook/address-list", "from-annotation").
+		WithProviderSpecific("address-list", "lan")
+
+	testCases := []struct {
+		name      string
+		template  string
+		maxLength int
+		expected  string
+	}{
+		{name: "Fixed comment", template: "managed", expected: "managed"},
+		{name: "Labels and type", template: "{{.Labels.resource}} via external-dns ({{.RecordType}})", expected: "ingress/default/web via external-dns (A)"},
+		{name: "Name, TTL and targets", template: "{{.DNSName}} {{.TTL}} {{index .Targets 0}}", expected: "web.example.com 300 192.0.2.1"},
+		{name: "Provider-specific properties", template: `list={{index .ProviderSpecific "address-list"}}`, expected: "list=lan"},
+		{name: "Missing label", template: "{{.Labels.owner}} via external-dns", expected: "via external-dns"},
+		{name: "Control characters", template: "line\none\ttab\x00 {{.RecordType}}", expected: "line one tab A"},
+		{name: "Length guard", template: "{{.DNSName}}", maxLength: 6, expected: "web.ex"},
+		{name: "Length guard on a character boundary", template: "ééé", maxLength: 5, expected: "éé"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			comment, err := renderComment(tc.template, ep, tc.maxLength)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if comment != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, comment)
+			}
+		})
+	}
+}
+
+func TestRenderCommentInvalid(t *testing.T) {
+	ep := endpoint.NewEndpoint("web.example.com", "A", "192.0.2.1")
+	if _, err := renderComment("{{.Labels.resource", ep, 0); err == nil {
+		t.Errorf("Expected error for an invalid template, got none")
+	}
+	if _, err := renderComment("{{.Unknown}}", ep, 0); err == nil {
+		t.Errorf("Expected error for an unknown field, got none")
+	}
+
+	_, err := NewMikrotikProvider(endpoint.NewDomainFilter(nil), &MikrotikDefaults{DefaultComment: "{{"}, []*MikrotikConnectionConfig{{BaseUrls: []string{"https://192.0.2.1"}}}, nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid comment template") {
+		t.Errorf("Expected the provider to reject an invalid template, got %v", err)
+	}
+}
+
+func TestTemplatedComment(t *testing.T) {
+	mikrotikProvider := &MikrotikProvider{
+		defaults: &MikrotikDefaults{
+			DefaultTTL:       3600,
+			DefaultComment:   "{{.Labels.resource}} via external-dns ({{.RecordType}})",
+			CommentMaxLength: 255,
+		},
+	}
+
+	desired := func() *endpoint.Endpoint {
+		return endpoint.NewEndpoint("web.example.com", "A", "192.0.2.1").WithLabel("resource", "ingress/default/web")
+	}
+
+	// created endpoints get the rendered comment
+	changes, _ := mikrotikProvider.changes(&plan.Changes{Create: []*endpoint.Endpoint{desired()}})
+	if comment, _ := changes.Create[0].GetProviderSpecificProperty("comment"); comment != "ingress/default/web via external-dns (A)" {
+		t.Fatalf("Expected the rendered comment, got %q", comment)
+	}
+
+	// endpoints read back from the router do not carry the labels, but the comment is still recognized as the default
+	current := endpoint.NewEndpointWithTTL("web.example.com", "A", 3600, "192.0.2.1").WithProviderSpecific("comment", "ingress/default/web via external-dns (A)")
+	if !mikrotikProvider.compareEndpoints(current, desired()) {
+		t.Errorf("Expected the rendered comment to be treated as the default")
+	}
+	changes, _ = mikrotikProvider.changes(&plan.Changes{UpdateOld: []*endpoint.Endpoint{current}, UpdateNew: []*endpoint.Endpoint{desired()}})
+	if len(changes.UpdateOld) != 0 || len(changes.UpdateNew) != 0 {
+		t.Errorf("Expected the update to be dropped, got %v -> %v", changes.UpdateOld, changes.UpdateNew)
+	}
+
+	// a comment rendered for another resource is a change
+	moved := endpoint.NewEndpoint("web.example.com", "A", "192.0.2.1").WithLabel("resource", "ingress/default/other")
+	if mikrotikProvider.compareEndpoints(current, moved) {
+		t.Errorf("Expected a comment rendered for another resource to be a change")
+	}
+
+	// explicit comments are still compared as they are
+	custom := desired().WithProviderSpecific("comment", "custom")
+	if mikrotikProvider.compareEndpoints(current, custom) {
+		t.Errorf("Expected an explicit comment to be a change")
+	}
+}
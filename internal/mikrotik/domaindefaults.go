@@ -0,0 +1,196 @@
+package mikrotik
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/caarlos0/env/v11"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// DomainDefaults holds the default values of the endpoints in a domain, used whenever an endpoint does not set them.
+// The domain is either a suffix (i.e. lab.example.com for the name and all its subdomains, or *.lab.example.com for
+// the subdomains only) or a regular expression matched against the whole name.
+type DomainDefaults struct {
+	Name  string `env:"MIKROTIK_DOMAIN_NAME"`
+	Regex string `env:"MIKROTIK_DOMAIN_REGEX"`
+
+	TTL            int64  `env:"MIKROTIK_DOMAIN_TTL"`
+	Comment        string `env:"MIKROTIK_DOMAIN_COMMENT"`
+	AddressList    string `env:"MIKROTIK_DOMAIN_ADDRESS_LIST"`
+	MatchSubdomain string `env:"MIKROTIK_DOMAIN_MATCH_SUBDOMAIN"`
+	Disabled       string `env:"MIKROTIK_DOMAIN_DISABLED"`
+
+	regex *regexp.Regexp
+}
+
+// domainEnvRegex matches the indexed environment variables used to configure the domain defaults (i.e. MIKROTIK_DOMAIN_1_TTL)
+var domainEnvRegex = regexp.MustCompile(`^MIKROTIK_DOMAIN_(\d+)_(.+)$`)
+
+// ReadDomainDefaults reads the default values of all domains from the given environment (as returned by os.Environ).
+// Domains are configured via indexed variables (i.e. MIKROTIK_DOMAIN_1_NAME, MIKROTIK_DOMAIN_1_TTL), and are returned in
+// the order of their index, which is the order in which they are matched.
+func ReadDomainDefaults(environ []string) ([]*DomainDefaults, error) {
+	indexed := map[int]map[string]string{}
+	for _, variable := range environ {
+		key, value, _ := strings.Cut(variable, "=")
+
+		match := domainEnvRegex.FindStringSubmatch(key)
+		if match == nil {
+			continue
+		}
+
+		index, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid domain index in %s: %v", key, err)
+		}
+		if indexed[index] == nil {
+			indexed[index] = map[string]string{}
+		}
+		indexed[index]["MIKROTIK_DOMAIN_"+match[2]] = value
+	}
+
+	indices := make([]int, 0, len(indexed))
+	for index := range indexed {
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+
+	domains := make([]*DomainDefaults, 0, len(indices))
+	for _, index := range indices {
+		domain := &DomainDefaults{}
+		if err := env.ParseWithOptions(domain, env.Options{Environment: indexed[index]}); err != nil {
+			return nil, fmt.Errorf("reading defaults of domain %d failed: %w", index, err)
+		}
+		if err := domain.validate(); err != nil {
+			return nil, fmt.Errorf("invalid defaults of domain %d: %w", index, err)
+		}
+		domains = append(domains, domain)
+	}
+
+	return domains, nil
+}
+
+// validate checks that the domain is set either as a suffix or as a regular expression, and compiles the latter
+func (d *DomainDefaults) validate() error {
+	if (d.Name == "") == (d.Regex == "") {
+		return fmt.Errorf("exactly one of the name and regex of the domain must be set")
+	}
+	if d.TTL < 0 {
+		return fmt.Errorf("TTL cannot be negative: %d", d.TTL)
+	}
+	if d.Comment != "" {
+		if _, err := parseCommentTemplate(d.Comment); err != nil {
+			return err
+		}
+	}
+	if d.Regex != "" {
+		regex, err := regexp.Compile(d.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid domain regex %q: %w", d.Regex, err)
+		}
+		d.regex = regex
+	}
+	return nil
+}
+
+// matches checks if the name belongs to the domain
+func (d *DomainDefaults) matches(name string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+
+	if d.Regex != "" {
+		regex := d.regex
+		if regex == nil {
+			compiled, err := regexp.Compile(d.Regex)
+			if err != nil {
+				return false
+			}
+			regex = compiled
+		}
+		return regex.MatchString(name)
+	}
+
+	domain := strings.ToLower(strings.TrimSuffix(d.Name, "."))
+	if subdomains, ok := strings.CutPrefix(domain, "*."); ok {
+		return strings.HasSuffix(name, "."+subdomains)
+	}
+	return name == domain || strings.HasSuffix(name, "."+domain)
+}
+
+// property returns the default value of a provider-specific property in the domain, or an empty string if it has none
+func (d *DomainDefaults) property(name string) string {
+	switch name {
+	case "comment":
+		return d.Comment
+	case "address-list":
+		return d.AddressList
+	case "match-subdomain":
+		return d.MatchSubdomain
+	case "disabled":
+		return d.Disabled
+	default:
+		return ""
+	}
+}
+
+// domainDefaults returns the defaults of the first domain the endpoint belongs to, or nil if there is none
+func (p *MikrotikProvider) domainDefaults(ep *endpoint.Endpoint) *DomainDefaults {
+	if p.defaults == nil {
+		return nil
+	}
+	for _, domain := range p.defaults.Domains {
+		if domain.matches(ep.DNSName) {
+			return domain
+		}
+	}
+	return nil
+}
+
+// defaultTTL returns the default TTL of an endpoint, from its domain or the global default
+func (p *MikrotikProvider) defaultTTL(ep *endpoint.Endpoint) endpoint.TTL {
+	if domain := p.domainDefaults(ep); domain != nil && domain.TTL > 0 {
+		return endpoint.TTL(domain.TTL)
+	}
+	if p.defaults == nil {
+		return 0
+	}
+	return endpoint.TTL(p.defaults.DefaultTTL)
+}
+
+// defaultProperty returns the default value of a provider-specific property of an endpoint, from its domain or the
+// given fallback. For comments, this is the template rendered by defaultComment.
+func (p *MikrotikProvider) defaultProperty(ep *endpoint.Endpoint, name, fallback string) string {
+	if domain := p.domainDefaults(ep); domain != nil && domain.property(name) != "" {
+		return domain.property(name)
+	}
+	return fallback
+}
+
+// domainProperties are the provider-specific properties that the domain defaults fill in, besides the comment
+var domainProperties = []string{"address-list", "match-subdomain", "disabled"}
+
+// applyDefaults fills in the TTL, comment and the provider-specific properties an endpoint does not set, with the
+// defaults of its domain or the global ones
+func (p *MikrotikProvider) applyDefaults(ep *endpoint.Endpoint) {
+	if !ep.RecordTTL.IsConfigured() {
+		ep.RecordTTL = p.defaultTTL(ep)
+	}
+
+	for _, name := range domainProperties {
+		if p.getProviderSpecificOrDefault(ep, name, "") != "" {
+			continue
+		}
+		if value := p.defaultProperty(ep, name, ""); value != "" {
+			ep.SetProviderSpecificProperty(name, value)
+		}
+	}
+
+	if p.getProviderSpecificOrDefault(ep, "comment", "") == "" {
+		if comment := p.defaultComment(ep); comment != "" {
+			ep.SetProviderSpecificProperty("comment", comment)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package mikrotik
+
+import (
+	"testing"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestReadDomainDefaults(t *testing.T) {
+	testCases := []struct {
+		name          string
+		environ       []string
+		expected      []DomainDefaults
+		expectedError bool
+	}{
+		{
+			name:     "No domains",
+			environ:  []string{"MIKROTIK_DEFAULT_TTL=60", "MIKROTIK_1_BASEURL=https://192.168.88.1:443"},
+			expected: []DomainDefaults{},
+		},
+		{
+			name: "Domains in the order of their index",
+			environ: []string{
+				"MIKROTIK_DOMAIN_2_REGEX=^db[0-9]+\\.example\\.com$",
+				"MIKROTIK_DOMAIN_2_DISABLED=true",
+				"MIKROTIK_DOMAIN_1_NAME=*.lab.example.com",
+				"MIKROTIK_DOMAIN_1_TTL=60",
+				"MIKROTIK_DOMAIN_1_ADDRESS_LIST=lab",
+				"MIKROTIK_DOMAIN_1_MATCH_SUBDOMAIN=true",
+				"MIKROTIK_DOMAIN_1_COMMENT=lab {{.RecordType}}",
+			},
+			expected: []DomainDefaults{
+				{Name: "*.lab.example.com", TTL: 60, AddressList: "lab", MatchSubdomain: "true", Comment: "lab {{.RecordType}}"},
+				{Regex: "^db[0-9]+\\.example\\.com$", Disabled: "true"},
+			},
+		},
+		{
+			name:          "Domain without name or regex",
+			environ:       []string{"MIKROTIK_DOMAIN_1_TTL=60"},
+			expectedError: true,
+		},
+		{
+			name:          "Domain with both name and regex",
+			environ:       []string{"MIKROTIK_DOMAIN_1_NAME=example.com", "MIKROTIK_DOMAIN_1_REGEX=example"},
+			expectedError: true,
+		},
+		{
+			name:          "Invalid regex",
+			environ:       []string{"MIKROTIK_DOMAIN_1_REGEX=("},
+			expectedError: true,
+		},
+		{
+			name:          "Invalid comment template",
+			environ:       []string{"MIKROTIK_DOMAIN_1_NAME=example.com", "MIKROTIK_DOMAIN_1_COMMENT={{"},
+			expectedError: true,
+		},
+		{
+			name:          "Invalid TTL",
+			environ:       []string{"MIKROTIK_DOMAIN_1_NAME=example.com", "MIKROTIK_DOMAIN_1_TTL=1h"},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			domains, err := ReadDomainDefaults(tc.environ)
+
+			if tc.expectedError {
+				if err == nil {
+					t.Fatalf("Expected error, got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(domains) != len(tc.expected) {
+				t.Fatalf("Expected %d domains, got %d", len(tc.expected), len(domains))
+			}
+			for i, expected := range tc.expected {
+				domain := *domains[i]
+				domain.regex = nil
+				if domain != expected {
+					t.Errorf("Expected domain %+v, got %+v", expected, domain)
+				}
+			}
+		})
+	}
+}
+
+func TestDomainDefaultsMatch(t *testing.T) {
+	testCases := []struct {
+		name     string
+		domain   DomainDefaults
+		dnsName  string
+		expected bool
+	}{
+		{name: "Suffix matches the domain itself", domain: DomainDefaults{Name: "lab.example.com"}, dnsName: "lab.example.com", expected: true},
+		{name: "Suffix matches subdomains", domain: DomainDefaults{Name: "lab.example.com"}, dnsName: "web.lab.example.com.", expected: true},
+		{name: "Suffix does not match other domains", domain: DomainDefaults{Name: "lab.example.com"}, dnsName: "mylab.example.com", expected: false},
+		{name: "Wildcard matches subdomains", domain: DomainDefaults{Name: "*.lab.example.com"}, dnsName: "Web.Lab.Example.com", expected: true},
+		{name: "Wildcard does not match the domain itself", domain: DomainDefaults{Name: "*.lab.example.com"}, dnsName: "lab.example.com", expected: false},
+		{name: "Regex matches", domain: DomainDefaults{Regex: `^db[0-9]+\.example\.com$`}, dnsName: "db1.example.com", expected: true},
+		{name: "Regex does not match", domain: DomainDefaults{Regex: `^db[0-9]+\.example\.com$`}, dnsName: "web.example.com", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if matches := tc.domain.matches(tc.dnsName); matches != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, matches)
+			}
+		})
+	}
+}
+
+func TestDomainDefaults(t *testing.T) {
+	mikrotikProvider := &MikrotikProvider{
+		defaults: &MikrotikDefaults{
+			DefaultTTL:     3600,
+			DefaultComment: "global",
+			Domains: []*DomainDefaults{
+				{Name: "*.lab.example.com", TTL: 60, AddressList: "lab"},
+				{Name: "*.prod.example.com", Comment: "prod {{.RecordType}}", Disabled: "false"},
+			},
+		},
+	}
+
+	changes, _ := mikrotikProvider.changes(&plan.Changes{Create: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("web.lab.example.com", "A", "192.0.2.1"),
+		endpoint.NewEndpoint("web.prod.example.com", "A", "192.0.2.2"),
+		endpoint.NewEndpoint("web.example.com", "A", "192.0.2.3"),
+		endpoint.NewEndpointWithTTL("db.lab.example.com", "A", 300, "192.0.2.4").WithProviderSpecific("address-list", "db"),
+	}})
+
+	expected := []struct {
+		ttl         endpoint.TTL
+		comment     string
+		addressList string
+	}{
+		{ttl: 60, comment: "global", addressList: "lab"},
+		{ttl: 3600, comment: "prod A"},
+		{ttl: 3600, comment: "global"},
+		{ttl: 300, comment: "global", addressList: "db"},
+	}
+	for i, ep := range changes.Create {
+		comment, _ := ep.GetProviderSpecificProperty("comment")
+		addressList, _ := ep.GetProviderSpecificProperty("address-list")
+		if ep.RecordTTL != expected[i].ttl || comment != expected[i].comment || addressList != expected[i].addressList {
+			t.Errorf("Expected %s to have TTL %d, comment %q and address list %q, got %v", ep.DNSName, expected[i].ttl, expected[i].comment, expected[i].addressList, ep)
+		}
+	}
+
+	// the domain defaults of the records read from the router are equivalent to unset values
+	current := endpoint.NewEndpointWithTTL("web.lab.example.com", "A", 60, "192.0.2.1").WithProviderSpecific("address-list", "lab").WithProviderSpecific("comment", "global")
+	desired := endpoint.NewEndpoint("web.lab.example.com", "A", "192.0.2.1")
+	if !mikrotikProvider.compareEndpoints(current, desired) {
+		t.Errorf("Expected the domain defaults to be treated as unset values")
+	}
+
+	// the global TTL is not the default in a domain with its own TTL
+	current = endpoint.NewEndpointWithTTL("web.lab.example.com", "A", 3600, "192.0.2.1").WithProviderSpecific("address-list", "lab")
+	if mikrotikProvider.compareEndpoints(current, desired) {
+		t.Errorf("Expected the global TTL to be a change in a domain with its own TTL")
+	}
+
+	// the address list of a domain is a change outside of it
+	current = endpoint.NewEndpointWithTTL("web.example.com", "A", 3600, "192.0.2.3").WithProviderSpecific("address-list", "lab")
+	desired = endpoint.NewEndpoint("web.example.com", "A", "192.0.2.3")
+	if mikrotikProvider.compareEndpoints(current, desired) {
+		t.Errorf("Expected an address list outside of its domain to be a change")
+	}
+}
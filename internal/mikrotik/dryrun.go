@@ -0,0 +1,99 @@
+package mikrotik
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DryRunOperation is a request that would have been sent to a router to change its records
+type DryRunOperation struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+
+	// Record is the record that would have been created or deleted, or the record as it is before an update
+	Record *DNSRecord `json:"record"`
+	// Fields are the fields that would have been changed by an update
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// String describes the operation as a line of a diff: created records are prefixed with +, deleted ones with - and
+// updated ones with ~, followed by the changed fields.
+func (o DryRunOperation) String() string {
+	record := fmt.Sprintf("%s %s", defaultValue(o.Record.Name, o.Record.Regexp), defaultValue(o.Record.Type, "A"))
+	if o.Record.ID != "" {
+		record += fmt.Sprintf(" (%s)", o.Record.ID)
+	}
+
+	switch o.Method {
+	case "PUT":
+		return fmt.Sprintf("+ %s %s", record, formatFields(o.Record))
+	case "PATCH":
+		current, _ := recordFields(o.Record)
+		changes := make([]string, 0, len(o.Fields))
+		for key, value := range o.Fields {
+			changes = append(changes, fmt.Sprintf("%s: %q -> %q", key, current[key], value))
+		}
+		slices.Sort(changes)
+		return fmt.Sprintf("~ %s %s", record, strings.Join(changes, ", "))
+	case "DELETE":
+		return fmt.Sprintf("- %s %s", record, formatFields(o.Record))
+	default:
+		return fmt.Sprintf("? %s %s %s", o.Method, o.Path, record)
+	}
+}
+
+// formatFields lists the fields of a record other than the ones identifying it, sorted by their API name
+func formatFields(record *DNSRecord) string {
+	fields, _ := recordFields(record)
+
+	var formatted []string
+	for key, value := range fields {
+		switch key {
+		case ".id", "name", "type", "regexp":
+			continue
+		}
+		formatted = append(formatted, fmt.Sprintf("%s=%s", key, value))
+	}
+	slices.Sort(formatted)
+	return strings.Join(formatted, " ")
+}
+
+// DryRunPlan holds the operations the last ApplyChanges call would have made in dry-run mode
+type DryRunPlan struct {
+	Time    time.Time      `json:"time"`
+	Routers []RouterDryRun `json:"routers"`
+}
+
+// RouterDryRun holds the operations that would have been made on a single router
+type RouterDryRun struct {
+	Router     string            `json:"router"`
+	Operations []DryRunOperation `json:"operations"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// dryRunRecorder collects the operations a client would have sent to the router, instead of sending them
+type dryRunRecorder struct {
+	mu         sync.Mutex
+	operations []DryRunOperation
+}
+
+// record adds an operation to the ones that would have been made
+func (r *dryRunRecorder) record(operation DryRunOperation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.operations = append(r.operations, operation)
+}
+
+// take returns the operations recorded so far, and starts over
+func (r *dryRunRecorder) take() []DryRunOperation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	operations := r.operations
+	r.operations = nil
+	return operations
+}
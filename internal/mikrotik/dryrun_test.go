@@ -0,0 +1,110 @@
+package mikrotik
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestDryRunOperationString(t *testing.T) {
+	testCases := []struct {
+		name      string
+		operation DryRunOperation
+		expected  string
+	}{
+		{
+			name:      "Create",
+			operation: DryRunOperation{Method: "PUT", Path: "ip/dns/static", Record: &DNSRecord{Name: "a.example.com", Address: "192.0.2.1", TTL: "1h"}},
+			expected:  "+ a.example.com A address=192.0.2.1 ttl=1h",
+		},
+		{
+			name:      "Update",
+			operation: DryRunOperation{Method: "PATCH", Path: "ip/dns/static/*1", Record: &DNSRecord{ID: "*1", Name: "a.example.com", Address: "192.0.2.1", TTL: "1h"}, Fields: map[string]string{"ttl": "5m", "address": "192.0.2.2"}},
+			expected:  `~ a.example.com A (*1) address: "192.0.2.1" -> "192.0.2.2", ttl: "1h" -> "5m"`,
+		},
+		{
+			name:      "Delete regexp record",
+			operation: DryRunOperation{Method: "DELETE", Path: "ip/dns/static/*2", Record: &DNSRecord{ID: "*2", Regexp: `.*\.example\.com`, Type: "CNAME", CName: "b.example.com"}},
+			expected:  `- .*\.example\.com CNAME (*2) cname=b.example.com`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := tc.operation.String(); actual != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	router := newMockRouter(t,
+		DNSRecord{ID: "*1", Name: "a.example.com", Address: "192.0.2.1", TTL: "1h"},
+		DNSRecord{ID: "*2", Name: "b.example.com", Address: "192.0.2.2", TTL: "1h"},
+	)
+
+	configs := []*MikrotikConnectionConfig{{Name: "dry-run", BaseUrls: []string{router.URL}, SkipTLSVerify: true}}
+	p, err := NewMikrotikProvider(endpoint.NewDomainFilter([]string{"example.com"}), &MikrotikDefaults{DefaultTTL: 3600}, configs, &MikrotikProviderConfig{DryRun: true})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	mikrotikProvider := p.(*MikrotikProvider)
+
+	if dryRun := mikrotikProvider.DryRunPlan().(*DryRunPlan); len(dryRun.Routers) != 0 {
+		t.Errorf("Expected an empty plan before any changes, got %+v", dryRun)
+	}
+
+	if _, err := mikrotikProvider.Records(context.Background()); err != nil {
+		t.Fatalf("Failed to read records: %v", err)
+	}
+	err = mikrotikProvider.ApplyChanges(context.Background(), &plan.Changes{
+		Create:    []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("c.example.com", "A", 3600, "192.0.2.3")},
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.example.com", "A", 3600, "192.0.2.1")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.example.com", "A", 3600, "192.0.2.10")},
+		Delete:    []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("b.example.com", "A", 3600, "192.0.2.2")},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// the router is left untouched
+	if addresses := router.addresses(); !slices.Equal(addresses, []string{"192.0.2.1", "192.0.2.2"}) {
+		t.Errorf("Expected the records to be left as they were, got %v", addresses)
+	}
+
+	dryRun := mikrotikProvider.DryRunPlan().(*DryRunPlan)
+	if len(dryRun.Routers) != 1 || dryRun.Routers[0].Router != "dry-run" || dryRun.Routers[0].Error != "" {
+		t.Fatalf("Expected a plan for the router, got %+v", dryRun)
+	}
+
+	var methods []string
+	for _, operation := range dryRun.Routers[0].Operations {
+		methods = append(methods, operation.Method+" "+operation.Path)
+	}
+	expected := []string{"DELETE ip/dns/static/*2", "PATCH ip/dns/static/*1", "PUT ip/dns/static"}
+	if !slices.Equal(methods, expected) {
+		t.Errorf("Expected operations %v, got %v", expected, methods)
+	}
+
+	// records are still read from the router, and the next plan replaces the previous one
+	if _, err := mikrotikProvider.Records(context.Background()); err != nil {
+		t.Fatalf("Failed to read records: %v", err)
+	}
+	if err := mikrotikProvider.ApplyChanges(context.Background(), &plan.Changes{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if dryRun := mikrotikProvider.DryRunPlan().(*DryRunPlan); len(dryRun.Routers[0].Operations) != 0 {
+		t.Errorf("Expected no operations, got %+v", dryRun.Routers[0].Operations)
+	}
+}
+
+func TestDryRunDisabled(t *testing.T) {
+	mikrotikProvider := &MikrotikProvider{config: &MikrotikProviderConfig{}}
+	if dryRun := mikrotikProvider.DryRunPlan(); dryRun != nil {
+		t.Errorf("Expected no plan when dry-run mode is disabled, got %+v", dryRun)
+	}
+}
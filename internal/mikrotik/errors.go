@@ -0,0 +1,204 @@
+package mikrotik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// ErrorKind tells why a request to the provider failed, so that the failure can be reported accordingly
+type ErrorKind string
+
+const (
+	// ErrorKindUnknown is a failure that could not be classified
+	ErrorKindUnknown ErrorKind = ""
+	// ErrorKindValidation is an endpoint that cannot be stored on a router. Sending it again will not help.
+	ErrorKindValidation ErrorKind = "validation"
+	// ErrorKindNotFound is a record or forwarder to change or use that does not exist on a router
+	ErrorKindNotFound ErrorKind = "not-found"
+	// ErrorKindConflict is a change that the records on a router keep from being applied (i.e. ambiguous or not owned)
+	ErrorKindConflict ErrorKind = "conflict"
+	// ErrorKindAuth is a router rejecting the credentials of the webhook
+	ErrorKindAuth ErrorKind = "auth"
+	// ErrorKindTransport is a router that could not be reached, or failed to process the request
+	ErrorKindTransport ErrorKind = "transport"
+)
+
+// errorKindPrecedence orders the kinds from the least to the most severe. When a request failed for several reasons,
+// it is reported as the most severe one.
+var errorKindPrecedence = []ErrorKind{ErrorKindValidation, ErrorKindNotFound, ErrorKindConflict, ErrorKindAuth, ErrorKindTransport}
+
+var (
+	// ErrInvalidEndpoint is returned for endpoints that cannot be stored on a router
+	ErrInvalidEndpoint = errors.New("endpoint cannot be stored on a router")
+	// ErrNotFound is returned when a record or forwarder does not exist on a router
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when the records on a router keep a change from being applied
+	ErrConflict = errors.New("conflicting records")
+	// ErrUnauthorized is returned when a router rejects the credentials of the webhook
+	ErrUnauthorized = errors.New("router rejected the credentials")
+
+	// ErrAlreadyExists is wrapped by the errors of requests that a router rejected because the entry already exists
+	ErrAlreadyExists = errors.New("entry already exists")
+	// ErrNoSuchItem is wrapped by the errors of requests that a router rejected because the entry does not exist
+	ErrNoSuchItem = errors.New("no such item")
+	// ErrPermissionDenied is wrapped by the errors of requests that the user of the webhook is not allowed to make
+	ErrPermissionDenied = errors.New("not enough permissions")
+)
+
+// routerOSErrors maps the failures described by RouterOS to their sentinel errors. The descriptions are matched as
+// substrings, since they come with prefixes and suffixes (i.e. "failure: entry already exists" or "not enough
+// permissions (9)").
+var routerOSErrors = []struct {
+	description string
+	err         error
+}{
+	{description: "already exists", err: ErrAlreadyExists},
+	{description: "already have such", err: ErrAlreadyExists},
+	{description: "no such item", err: ErrNoSuchItem},
+	{description: "not enough permissions", err: ErrPermissionDenied},
+}
+
+// routerOSError returns the sentinel error of a failure described by RouterOS, or nil if it is not a known one
+func routerOSError(description string) error {
+	description = strings.ToLower(description)
+	for _, known := range routerOSErrors {
+		if strings.Contains(description, known.description) {
+			return known.err
+		}
+	}
+	return nil
+}
+
+// EndpointError is a failure caused by a single endpoint
+type EndpointError struct {
+	Endpoint *endpoint.Endpoint
+	Err      error
+}
+
+func (e *EndpointError) Error() string {
+	return fmt.Sprintf("endpoint %s %s %v: %v", e.Endpoint.DNSName, e.Endpoint.RecordType, e.Endpoint.Targets, e.Err)
+}
+
+func (e *EndpointError) Unwrap() error {
+	return e.Err
+}
+
+// ProviderError is returned when Records or ApplyChanges fail. It tells why they failed and which endpoints caused it.
+type ProviderError struct {
+	Err error
+}
+
+// newProviderError wraps the error into a *ProviderError, unless it is nil
+func newProviderError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ProviderError{Err: err}
+}
+
+func (e *ProviderError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// Kind returns the most severe kind of the failures the error is made of, or ErrorKindUnknown if none of them could be
+// classified
+func (e *ProviderError) Kind() ErrorKind {
+	kinds := errorKinds(e.Err)
+	for i := len(errorKindPrecedence) - 1; i >= 0; i-- {
+		if slices.Contains(kinds, errorKindPrecedence[i]) {
+			return errorKindPrecedence[i]
+		}
+	}
+	return ErrorKindUnknown
+}
+
+// ErrorKind returns the kind of the error as a string, as expected by the webhook
+func (e *ProviderError) ErrorKind() string {
+	return string(e.Kind())
+}
+
+// Endpoints returns the endpoints that caused the error, if known
+func (e *ProviderError) Endpoints() []*endpoint.Endpoint {
+	var endpoints []*endpoint.Endpoint
+	walkErrors(e.Err, func(err error) bool {
+		if endpointErr, ok := err.(*EndpointError); ok && !slices.Contains(endpoints, endpointErr.Endpoint) {
+			endpoints = append(endpoints, endpointErr.Endpoint)
+		}
+		return true
+	})
+	return endpoints
+}
+
+// errorKinds returns the known kinds of the failures an error is made of. Each chain of wrapped errors is classified by
+// its first error with a known kind, and joined errors are classified separately.
+func errorKinds(err error) []ErrorKind {
+	var kinds []ErrorKind
+	walkErrors(err, func(err error) bool {
+		kind := errorKind(err)
+		if kind != ErrorKindUnknown {
+			kinds = append(kinds, kind)
+			return false
+		}
+		return true
+	})
+	return kinds
+}
+
+// errorKind classifies a single error, without looking at the errors it wraps
+func errorKind(err error) ErrorKind {
+	switch err {
+	case ErrInvalidEndpoint:
+		return ErrorKindValidation
+	case ErrNotFound, ErrNoSuchItem:
+		return ErrorKindNotFound
+	case ErrConflict, ErrNotOwned, ErrAlreadyExists:
+		return ErrorKindConflict
+	case ErrUnauthorized, ErrPermissionDenied:
+		return ErrorKindAuth
+	case ErrCircuitOpen, ErrNotConnected, context.DeadlineExceeded:
+		return ErrorKindTransport
+	}
+
+	switch err := err.(type) {
+	case *requestError:
+		switch {
+		case err.StatusCode == 401 || err.StatusCode == 403:
+			return ErrorKindAuth
+		case err.StatusCode == 404:
+			return ErrorKindNotFound
+		case err.StatusCode >= 500:
+			return ErrorKindTransport
+		}
+	case net.Error:
+		return ErrorKindTransport
+	}
+
+	return ErrorKindUnknown
+}
+
+// walkErrors calls fn for the error and all the errors it wraps, depth first. The errors wrapped by an error are
+// skipped if fn returns false for it.
+func walkErrors(err error, fn func(err error) bool) {
+	if err == nil || !fn(err) {
+		return
+	}
+
+	switch wrapper := err.(type) {
+	case interface{ Unwrap() error }:
+		walkErrors(wrapper.Unwrap(), fn)
+	case interface{ Unwrap() []error }:
+		for _, wrapped := range wrapper.Unwrap() {
+			walkErrors(wrapped, fn)
+		}
+	}
+}
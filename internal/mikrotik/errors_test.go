@@ -0,0 +1,107 @@
+package mikrotik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestProviderErrorKind(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected ErrorKind
+	}{
+		{name: "Unclassified error", err: errors.New("boom"), expected: ErrorKindUnknown},
+		{name: "Invalid endpoint", err: fmt.Errorf("%w: bad target", ErrInvalidEndpoint), expected: ErrorKindValidation},
+		{name: "Missing record", err: fmt.Errorf("no record found: %w", ErrNotFound), expected: ErrorKindNotFound},
+		{name: "Record not owned", err: ErrNotOwned, expected: ErrorKindConflict},
+		{name: "Rejected login", err: fmt.Errorf("login failed: %w: %w", ErrUnauthorized, errors.New("invalid user name or password")), expected: ErrorKindAuth},
+		{name: "Forbidden request", err: &requestError{StatusCode: 403, Status: "403 Forbidden"}, expected: ErrorKindAuth},
+		{name: "Missing item", err: &requestError{StatusCode: 404, Status: "404 Not Found"}, expected: ErrorKindNotFound},
+		{name: "Router failure", err: &requestError{StatusCode: 500, Status: "500 Internal Server Error"}, expected: ErrorKindTransport},
+		{name: "Bad request", err: &requestError{StatusCode: 400, Status: "400 Bad Request"}, expected: ErrorKindUnknown},
+		{name: "Existing entry", err: &requestError{StatusCode: 400, Status: "400 Bad Request", Detail: "failure: entry already exists"}, expected: ErrorKindConflict},
+		{name: "Missing permissions", err: &requestError{StatusCode: 400, Status: "400 Bad Request", Detail: "not enough permissions (9)"}, expected: ErrorKindAuth},
+		{name: "Missing item trap", err: &apiError{Category: "0", Message: "no such item"}, expected: ErrorKindNotFound},
+		{name: "Open circuit", err: fmt.Errorf("router1: %w", ErrCircuitOpen), expected: ErrorKindTransport},
+		{name: "Timeout", err: context.DeadlineExceeded, expected: ErrorKindTransport},
+		{
+			name:     "Most severe kind of joined errors",
+			err:      errors.Join(fmt.Errorf("%w: bad target", ErrInvalidEndpoint), fmt.Errorf("router1: %w", ErrCircuitOpen)),
+			expected: ErrorKindTransport,
+		},
+		{
+			name:     "Known kinds win over unclassified errors",
+			err:      errors.Join(errors.New("boom"), fmt.Errorf("%w: bad target", ErrInvalidEndpoint)),
+			expected: ErrorKindValidation,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := &ProviderError{Err: tc.err}
+			if kind := err.Kind(); kind != tc.expected {
+				t.Errorf("Expected kind %q, got %q", tc.expected, kind)
+			}
+		})
+	}
+}
+
+func TestProviderErrorEndpoints(t *testing.T) {
+	first := endpoint.NewEndpoint("a.example.com", "A", "192.0.2.1")
+	second := endpoint.NewEndpoint("b.example.com", "A", "192.0.2.2")
+
+	err := &ProviderError{Err: errors.Join(
+		&EndpointError{Endpoint: first, Err: ErrInvalidEndpoint},
+		fmt.Errorf("router1: %w", &TransactionError{Err: &EndpointError{Endpoint: second, Err: ErrNotFound}}),
+		fmt.Errorf("router2: %w", &TransactionError{Err: &EndpointError{Endpoint: second, Err: ErrNotFound}}),
+	)}
+
+	endpoints := err.Endpoints()
+	if len(endpoints) != 2 || endpoints[0] != first || endpoints[1] != second {
+		t.Errorf("Expected endpoints %v and %v, got %v", first, second, endpoints)
+	}
+	if newProviderError(nil) != nil {
+		t.Errorf("Expected no error to be wrapped into no error")
+	}
+}
+
+func TestApplyChangesSkipsInvalidEndpoints(t *testing.T) {
+	router := newMockRouter(t,
+		DNSRecord{ID: "*1", Name: "a.example.com", Address: "192.0.2.1", TTL: "1h"},
+		DNSRecord{ID: "*2", Name: "b.example.com", Address: "192.0.2.2", TTL: "1h"},
+	)
+	mikrotikProvider := &MikrotikProvider{
+		clients:  []*MikrotikApiClient{router.client(t, "router")},
+		defaults: &MikrotikDefaults{DefaultTTL: 3600},
+	}
+
+	invalid := endpoint.NewEndpointWithTTL("c.example.com", "A", 3600, "not-an-ip")
+	err := mikrotikProvider.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{invalid, endpoint.NewEndpointWithTTL("d.example.com", "A", 3600, "192.0.2.4")},
+		// the old version of an update is kept when its new version is invalid
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.example.com", "A", 3600, "192.0.2.1")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.example.com", "MX", 3600, "not-a-preference mail.example.com")},
+		Delete:    []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("b.example.com", "A", 3600, "192.0.2.2")},
+	})
+
+	var providerErr *ProviderError
+	if !errors.As(err, &providerErr) {
+		t.Fatalf("Expected a *ProviderError, got %v", err)
+	}
+	if kind := providerErr.Kind(); kind != ErrorKindValidation {
+		t.Errorf("Expected kind %q, got %q", ErrorKindValidation, kind)
+	}
+	if endpoints := providerErr.Endpoints(); len(endpoints) != 2 || endpoints[0] != invalid {
+		t.Errorf("Expected the invalid endpoints to be reported, got %v", endpoints)
+	}
+	if addresses := router.addresses(); strings.Join(addresses, ",") != "192.0.2.1,192.0.2.4" {
+		t.Errorf("Expected records 192.0.2.1 and 192.0.2.4, got %v", addresses)
+	}
+}
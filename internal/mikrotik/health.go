@@ -0,0 +1,245 @@
+package mikrotik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RouterStatus describes the state of a router as reported by the readiness probe
+type RouterStatus struct {
+	Name           string     `json:"name"`
+	Ready          bool       `json:"ready"`
+	Connected      bool       `json:"connected"`
+	Identity       string     `json:"identity,omitempty"`
+	BoardName      string     `json:"boardName,omitempty"`
+	Version        string     `json:"version,omitempty"`
+	ActiveURL      string     `json:"activeUrl"`
+	CircuitBreaker string     `json:"circuitBreaker"`
+	Diverged       bool       `json:"diverged"`
+	LastCheck      *time.Time `json:"lastCheck,omitempty"`
+	Errors         []string   `json:"errors,omitempty"`
+}
+
+// ErrNotConnected is returned while the webhook has not been able to connect to a router since it started
+var ErrNotConnected = errors.New("not connected to the router yet")
+
+// routerHealth holds the outcome of the last health check, Records and ApplyChanges call of a router
+type routerHealth struct {
+	// Whether a health check of the router ever succeeded
+	connected bool
+
+	identity  *MikrotikSystemIdentity
+	info      *MikrotikSystemInfo
+	checkedAt time.Time
+
+	checkErr   error
+	recordsErr error
+	applyErr   error
+}
+
+// errors returns the failures that keep the router from being ready
+func (h *routerHealth) errors() []error {
+	var errs []error
+	if h.checkErr != nil {
+		errs = append(errs, fmt.Errorf("health check failed: %w", h.checkErr))
+	}
+	if h.recordsErr != nil {
+		errs = append(errs, fmt.Errorf("reading records failed: %w", h.recordsErr))
+	}
+	// Changes rejected because of their endpoints, i.e. conflicting or not owned records, do not affect the router
+	if h.applyErr != nil && routerError(h.applyErr) {
+		errs = append(errs, fmt.Errorf("applying changes failed: %w", h.applyErr))
+	}
+	return errs
+}
+
+// routerError checks if a failure is caused by the router rather than by the request, i.e. the router could not be
+// reached or rejected the credentials of the webhook
+func routerError(err error) bool {
+	kind := (&ProviderError{Err: err}).Kind()
+	return kind == ErrorKindTransport || kind == ErrorKindAuth
+}
+
+// routerHealth returns the health of a router, creating it if needed. Must be called with the lock held.
+func (p *MikrotikProvider) routerHealth(client *MikrotikApiClient) *routerHealth {
+	if p.health == nil {
+		p.health = map[string]*routerHealth{}
+	}
+	if p.health[client.RouterName()] == nil {
+		p.health[client.RouterName()] = &routerHealth{}
+	}
+	return p.health[client.RouterName()]
+}
+
+// checkRouters fetches the identity and system information of all routers, caching them for the readiness probe
+func (p *MikrotikProvider) checkRouters(ctx context.Context) []error {
+	errs := make([]error, len(p.clients))
+	for i, client := range p.clients {
+		errs[i] = p.checkRouter(ctx, client)
+	}
+	return errs
+}
+
+// checkRouter fetches the identity and system information of a single router, caching them for the readiness probe
+func (p *MikrotikProvider) checkRouter(ctx context.Context, client *MikrotikApiClient) error {
+	info, err := client.GetSystemInfo(ctx)
+	var identity *MikrotikSystemIdentity
+	if err == nil {
+		identity, err = client.GetSystemIdentity(ctx)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	health := p.routerHealth(client)
+	health.checkedAt = time.Now()
+	if err != nil {
+		if health.checkErr == nil {
+			log.Warnf("health check of %s failed: %v", client.RouterName(), err)
+		}
+		health.checkErr = err
+		return err
+	}
+
+	if health.checkErr != nil {
+		log.Infof("health check of %s succeeded again", client.RouterName())
+	}
+	if !health.connected {
+		log.Infof("connected to %s: board %s running RouterOS version %s (%s)", client.RouterName(), info.BoardName, info.Version, info.ArchitectureName)
+	}
+	health.connected = true
+	health.checkErr = nil
+	health.identity = identity
+	health.info = info
+	return nil
+}
+
+// connect checks the routers that could not be connected to every interval, until all of them are connected or ctx is done
+func (p *MikrotikProvider) connect(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, client := range p.clients {
+			if p.routerConnected(client) {
+				continue
+			}
+			if err := p.checkRouter(ctx, client); err != nil {
+				log.Warnf("still not connected to %s, retrying in %v", client.RouterName(), interval)
+			}
+		}
+		if p.Connected() == nil {
+			log.Infof("connected to all routers")
+			return
+		}
+	}
+}
+
+// routerConnected checks if the webhook managed to connect to the router since it started
+func (p *MikrotikProvider) routerConnected(client *MikrotikApiClient) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.routerHealth(client).connected
+}
+
+// Connected checks if the webhook managed to connect to all routers since it started. Until then, the webhook neither
+// serves records nor applies changes, since the records of routers that were never reached would be missing.
+func (p *MikrotikProvider) Connected() error {
+	errs := make([]error, len(p.clients))
+	for i, client := range p.clients {
+		if !p.routerConnected(client) {
+			errs[i] = ErrNotConnected
+		}
+	}
+	return routerErrors(p.clients, errs)
+}
+
+// runHealthChecks checks all routers every interval, until ctx is done
+func (p *MikrotikProvider) runHealthChecks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.checkRouters(ctx)
+		}
+	}
+}
+
+// routerFailures returns the failures that keep a router from being ready. Must be called with the lock held.
+func (p *MikrotikProvider) routerFailures(client *MikrotikApiClient) []error {
+	health := p.routerHealth(client)
+	errs := health.errors()
+	if !health.connected && health.checkErr == nil {
+		errs = append(errs, ErrNotConnected)
+	}
+	if err := client.Ready(); err != nil {
+		errs = append([]error{err}, errs...)
+	}
+	return errs
+}
+
+// Ready checks if all routers can be managed. A router is not ready while its circuit breaker is open, if its last
+// health check or Records call failed, or if its last ApplyChanges call failed to reach it.
+func (p *MikrotikProvider) Ready() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	errs := make([]error, len(p.clients))
+	for i, client := range p.clients {
+		errs[i] = errors.Join(p.routerFailures(client)...)
+	}
+	return routerErrors(p.clients, errs)
+}
+
+// Status returns the state of all routers, as a []RouterStatus, for the readiness probe
+func (p *MikrotikProvider) Status() any {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	statuses := make([]RouterStatus, 0, len(p.clients))
+	for _, client := range p.clients {
+		health := p.routerHealth(client)
+		status := RouterStatus{
+			Name:           client.RouterName(),
+			Connected:      health.connected,
+			ActiveURL:      client.ActiveURL(),
+			CircuitBreaker: client.breaker.State().String(),
+			Diverged:       p.diverged[client.RouterName()],
+		}
+		if health.identity != nil {
+			status.Identity = health.identity.Name
+		}
+		if health.info != nil {
+			status.BoardName = health.info.BoardName
+			status.Version = health.info.Version
+		}
+		if !health.checkedAt.IsZero() {
+			checkedAt := health.checkedAt
+			status.LastCheck = &checkedAt
+		}
+
+		errs := p.routerFailures(client)
+		for _, err := range errs {
+			status.Errors = append(status.Errors, err.Error())
+		}
+		status.Ready = len(errs) == 0
+
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
@@ -0,0 +1,147 @@
+package mikrotik
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestReadiness(t *testing.T) {
+	router := newMockRouter(t, DNSRecord{ID: "*1", Name: "a.example.com", Address: "192.0.2.1"})
+	mikrotikProvider := &MikrotikProvider{
+		clients:      []*MikrotikApiClient{router.client(t, "readiness")},
+		defaults:     &MikrotikDefaults{DefaultTTL: 3600},
+		domainFilter: endpoint.NewDomainFilter([]string{"example.com"}),
+	}
+
+	// expectReady checks the readiness of the provider, and that its status reports the given error
+	expectReady := func(ready bool, reason string) {
+		t.Helper()
+
+		err := mikrotikProvider.Ready()
+		if ready && err != nil {
+			t.Fatalf("Expected provider to be ready, got %v", err)
+		}
+		if !ready && (err == nil || !strings.Contains(err.Error(), reason)) {
+			t.Fatalf("Expected provider not to be ready because %s, got %v", reason, err)
+		}
+
+		status := mikrotikProvider.Status().([]RouterStatus)[0]
+		if status.Ready != ready {
+			t.Errorf("Expected status ready to be %v, got %v", ready, status.Ready)
+		}
+		if !ready && (len(status.Errors) != 1 || !strings.Contains(status.Errors[0], reason)) {
+			t.Errorf("Expected status errors to report %s, got %v", reason, status.Errors)
+		}
+	}
+
+	mikrotikProvider.checkRouters(context.Background())
+	expectReady(true, "")
+	status := mikrotikProvider.Status().([]RouterStatus)[0]
+	if status.Name != "readiness" || status.Identity != "mock-router" || status.BoardName != "mock" || status.Version != "7.16 (stable)" {
+		t.Errorf("Expected status to report the router identity and version, got %+v", status)
+	}
+	if status.LastCheck == nil {
+		t.Errorf("Expected status to report the time of the last check")
+	}
+
+	// the router becomes unreachable until it is checked successfully again
+	router.setFailing(true)
+	mikrotikProvider.checkRouters(context.Background())
+	expectReady(false, "health check failed")
+	router.setFailing(false)
+	mikrotikProvider.checkRouters(context.Background())
+	expectReady(true, "")
+
+	// a failed Records call makes the router unready until records are read again
+	router.setFailing(true)
+	if _, err := mikrotikProvider.Records(context.Background()); err == nil {
+		t.Fatalf("Expected error, got none")
+	}
+	router.setFailing(false)
+	expectReady(false, "reading records failed")
+	if _, err := mikrotikProvider.Records(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expectReady(true, "")
+
+	// changes rejected by the router do not affect its readiness
+	router.setRejectAddress("192.0.2.9")
+	err := mikrotikProvider.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("b.example.com", "A", "192.0.2.9")},
+	})
+	if err == nil {
+		t.Fatalf("Expected error, got none")
+	}
+	expectReady(true, "")
+
+	// an ApplyChanges call failing to reach the router makes it unready until changes are applied again
+	router.setFailing(true)
+	err = mikrotikProvider.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("b.example.com", "A", "192.0.2.2")},
+	})
+	if err == nil {
+		t.Fatalf("Expected error, got none")
+	}
+	router.setFailing(false)
+	expectReady(false, "applying changes failed")
+	err = mikrotikProvider.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("b.example.com", "A", "192.0.2.2")},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expectReady(true, "")
+}
+
+func TestLazyStartup(t *testing.T) {
+	router := newMockRouter(t, DNSRecord{ID: "*1", Name: "a.example.com", Address: "192.0.2.1"})
+	router.setFailing(true)
+
+	configs := []*MikrotikConnectionConfig{{Name: "lazy", BaseUrls: []string{router.URL}, SkipTLSVerify: true}}
+	providerConfig := &MikrotikProviderConfig{ConnectRetryInterval: 10 * time.Millisecond}
+	p, err := NewMikrotikProvider(endpoint.NewDomainFilter([]string{"example.com"}), &MikrotikDefaults{DefaultTTL: 3600}, configs, providerConfig)
+	if err != nil {
+		t.Fatalf("Expected the provider to start without a connection, got %v", err)
+	}
+	mikrotikProvider := p.(*MikrotikProvider)
+
+	if err := mikrotikProvider.Connected(); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("Expected not connected error, got %v", err)
+	}
+	if err := mikrotikProvider.Ready(); err == nil {
+		t.Errorf("Expected provider not to be ready while not connected")
+	}
+
+	// once the router comes back, the provider connects without a restart
+	router.setFailing(false)
+	deadline := time.Now().Add(5 * time.Second)
+	for mikrotikProvider.Connected() != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the provider to connect once the router is back")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := mikrotikProvider.Ready(); err != nil {
+		t.Errorf("Expected provider to be ready once connected, got %v", err)
+	}
+	if status := mikrotikProvider.Status().([]RouterStatus)[0]; !status.Connected || status.Version != "7.16 (stable)" {
+		t.Errorf("Expected status to report the connected router, got %+v", status)
+	}
+}
+
+func TestStartupWithoutRetry(t *testing.T) {
+	router := newMockRouter(t)
+	router.setFailing(true)
+
+	configs := []*MikrotikConnectionConfig{{Name: "no-retry", BaseUrls: []string{router.URL}, SkipTLSVerify: true}}
+	providerConfig := &MikrotikProviderConfig{ConnectRetryInterval: 0}
+	if _, err := NewMikrotikProvider(endpoint.NewDomainFilter(nil), &MikrotikDefaults{}, configs, providerConfig); err == nil {
+		t.Errorf("Expected error when connection retries are disabled, got none")
+	}
+}
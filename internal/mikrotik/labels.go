@@ -0,0 +1,137 @@
+package mikrotik
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// labelsTag starts the labels of an endpoint in the comment of its static entries, i.e. edns:o=default;r=ingress/default/web
+const labelsTag = "edns:"
+
+// labelAliases are the short names of the common labels, to keep the comments compact
+var labelAliases = map[string]string{
+	endpoint.OwnerLabelKey:    "o",
+	endpoint.ResourceLabelKey: "r",
+}
+
+// skippedLabels are only meaningful to the TXT registry, so they are not stored in comments
+var skippedLabels = []string{endpoint.OwnedRecordLabelKey, endpoint.AWSSDDescriptionLabel, "txt-encryption-nonce"}
+
+// labelEscaper escapes the characters of label keys and values that are used by the encoding
+var labelEscaper = strings.NewReplacer("%", "%25", ";", "%3B", "=", "%3D", " ", "%20")
+
+// encodeLabels returns the comment with the labels appended to it, after a space.
+// Labels are sorted by key, so that the same labels are always encoded the same way.
+func encodeLabels(comment string, labels endpoint.Labels) string {
+	var pairs []string
+	for key, value := range labels {
+		if value == "" || slices.Contains(skippedLabels, key) {
+			continue
+		}
+		if alias, ok := labelAliases[key]; ok {
+			key = alias
+		}
+		pairs = append(pairs, labelEscaper.Replace(key)+"="+labelEscaper.Replace(value))
+	}
+	if len(pairs) == 0 {
+		return comment
+	}
+	slices.Sort(pairs)
+
+	encoded := labelsTag + strings.Join(pairs, ";")
+	if comment == "" {
+		return encoded
+	}
+	return comment + " " + encoded
+}
+
+// decodeLabels splits a comment into the text in front of the labels and the labels appended to it.
+// Comments without labels are returned as they are, along with empty labels.
+func decodeLabels(comment string) (string, endpoint.Labels, error) {
+	labels := endpoint.NewLabels()
+
+	text, encoded, found := "", "", false
+	if strings.HasPrefix(comment, labelsTag) {
+		encoded, found = strings.TrimPrefix(comment, labelsTag), true
+	} else if index := strings.LastIndex(comment, " "+labelsTag); index >= 0 {
+		text, encoded, found = comment[:index], comment[index+len(labelsTag)+1:], true
+	}
+	if !found || strings.Contains(encoded, " ") {
+		return comment, labels, nil
+	}
+
+	for _, pair := range strings.Split(encoded, ";") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return comment, labels, fmt.Errorf("invalid label %q in comment: %s", pair, comment)
+		}
+		key, keyErr := url.PathUnescape(key)
+		value, valueErr := url.PathUnescape(value)
+		if keyErr != nil || valueErr != nil {
+			return comment, labels, fmt.Errorf("invalid label %q in comment: %s", pair, comment)
+		}
+		for name, alias := range labelAliases {
+			if key == alias {
+				key = name
+			}
+		}
+		labels[key] = value
+	}
+
+	return text, labels, nil
+}
+
+// commentLabelsEnabled checks if the labels of the endpoints are stored in the comment of their static entries.
+func (p *MikrotikProvider) commentLabelsEnabled() bool {
+	return p.config != nil && p.config.CommentLabels
+}
+
+// withCommentLabels returns a copy of the endpoint with its labels appended to its comment, as stored on the router.
+// ExternalDNS sets no owner label with the noop registry, so endpoints without one are stamped with the owner ID of the
+// webhook, if configured.
+func (p *MikrotikProvider) withCommentLabels(ep *endpoint.Endpoint) *endpoint.Endpoint {
+	labels := ep.Labels
+	if p.config.OwnerID != "" && labels[endpoint.OwnerLabelKey] == "" {
+		labels = endpoint.NewLabels()
+		for key, value := range ep.Labels {
+			labels[key] = value
+		}
+		labels[endpoint.OwnerLabelKey] = p.config.OwnerID
+	}
+	comment := encodeLabels(p.getProviderSpecificOrDefault(ep, "comment", ""), labels)
+
+	copied := ep.DeepCopy()
+	copied.DeleteProviderSpecificProperty("webhook/comment")
+	if comment == "" {
+		copied.DeleteProviderSpecificProperty("comment")
+	} else {
+		copied.SetProviderSpecificProperty("comment", comment)
+	}
+	return copied
+}
+
+// readCommentLabels moves the labels stored in the comment of an endpoint read from the router to its labels
+func (p *MikrotikProvider) readCommentLabels(ep *endpoint.Endpoint) {
+	comment, labels, err := decodeLabels(p.getProviderSpecificOrDefault(ep, "comment", ""))
+	if err != nil {
+		log.Warnf("Failed to read labels of endpoint %v: %v", ep, err)
+		return
+	}
+
+	ep.Labels = labels
+	if comment == "" {
+		ep.DeleteProviderSpecificProperty("comment")
+	} else {
+		ep.SetProviderSpecificProperty("comment", comment)
+	}
+}
+
+// sameLabels checks if two endpoints carry the same labels, as far as they are stored in comments
+func sameLabels(a, b *endpoint.Endpoint) bool {
+	return encodeLabels("", a.Labels) == encodeLabels("", b.Labels)
+}
@@ -0,0 +1,212 @@
+package mikrotik
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestCommentLabelsEncoding(t *testing.T) {
+	testCases := []struct {
+		name     string
+		comment  string
+		labels   endpoint.Labels
+		expected string
+	}{
+		{name: "No labels", comment: "web", labels: endpoint.Labels{}, expected: "web"},
+		{name: "Labels only", comment: "", labels: endpoint.Labels{"owner": "default", "resource": "ingress/default/web"}, expected: "edns:o=default;r=ingress/default/web"},
+		{name: "Comment and labels", comment: "web", labels: endpoint.Labels{"owner": "default"}, expected: "web edns:o=default"},
+		{name: "Other labels", comment: "", labels: endpoint.Labels{"team": "a b;c=d", "owner": "default"}, expected: "edns:o=default;team=a%20b%3Bc%3Dd"},
+		{name: "Registry labels are left out", comment: "", labels: endpoint.Labels{"owner": "default", "ownedRecord": "web.example.com"}, expected: "edns:o=default"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			encoded := encodeLabels(tc.comment, tc.labels)
+			if encoded != tc.expected {
+				t.Fatalf("Expected %q, got %q", tc.expected, encoded)
+			}
+
+			comment, labels, err := decodeLabels(encoded)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if comment != tc.comment {
+				t.Errorf("Expected comment %q, got %q", tc.comment, comment)
+			}
+			delete(tc.labels, "ownedRecord")
+			if !maps.Equal(labels, tc.labels) {
+				t.Errorf("Expected labels %v, got %v", tc.labels, labels)
+			}
+		})
+	}
+}
+
+func TestDecodeLabelsInvalid(t *testing.T) {
+	if comment, labels, err := decodeLabels("edns: is a tag"); err != nil || comment != "edns: is a tag" || len(labels) != 0 {
+		t.Errorf("Expected a comment with spaces to be left as is, got %q %v %v", comment, labels, err)
+	}
+	if _, _, err := decodeLabels("web edns:o"); err == nil {
+		t.Errorf("Expected error for a label without value, got none")
+	}
+}
+
+func TestCommentLabels(t *testing.T) {
+	router := newMockRouter(t,
+		DNSRecord{ID: "*1", Name: "a.example.com", Address: "192.0.2.1", TTL: "1h", Comment: "web edns:o=default;r=ingress/default/a"},
+	)
+	mikrotikProvider := &MikrotikProvider{
+		clients:      []*MikrotikApiClient{router.client(t, "comment-labels")},
+		defaults:     &MikrotikDefaults{DefaultTTL: 3600},
+		domainFilter: endpoint.NewDomainFilter([]string{"example.com"}),
+		config:       &MikrotikProviderConfig{CommentLabels: true},
+	}
+
+	endpoints, err := mikrotikProvider.Records(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to read records: %v", err)
+	}
+	if len(endpoints) != 1 || endpoints[0].Labels["owner"] != "default" || endpoints[0].Labels["resource"] != "ingress/default/a" {
+		t.Fatalf("Expected the labels to be read from the comment, got %v", endpoints)
+	}
+	if comment, _ := endpoints[0].GetProviderSpecificProperty("comment"); comment != "web" {
+		t.Errorf("Expected the comment without the labels, got %q", comment)
+	}
+
+	// a change of the labels alone updates the comment in place
+	updated := endpoint.NewEndpointWithTTL("a.example.com", "A", 3600, "192.0.2.1").WithProviderSpecific("comment", "web").WithLabel("owner", "default").WithLabel("resource", "ingress/default/b")
+	created := endpoint.NewEndpointWithTTL("b.example.com", "A", 3600, "192.0.2.2").WithLabel("owner", "default")
+	err = mikrotikProvider.ApplyChanges(context.Background(), &plan.Changes{
+		Create:    []*endpoint.Endpoint{created},
+		UpdateOld: endpoints,
+		UpdateNew: []*endpoint.Endpoint{updated},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	comments := router.comments()
+	if comments["a.example.com"] != "web edns:o=default;r=ingress/default/b" {
+		t.Errorf("Expected the labels of the updated record to be stored in its comment, got %q", comments["a.example.com"])
+	}
+	if comments["b.example.com"] != "edns:o=default" {
+		t.Errorf("Expected the labels of the created record to be stored in its comment, got %q", comments["b.example.com"])
+	}
+	if _, ok := created.GetProviderSpecificProperty("comment"); ok {
+		t.Errorf("Expected the endpoint of external-dns to be left untouched")
+	}
+}
+
+func TestCommentLabelsOwner(t *testing.T) {
+	router := newMockRouter(t,
+		DNSRecord{ID: "*1", Name: "a.example.com", Address: "192.0.2.1", TTL: "1h", Comment: "edns:o=other"},
+	)
+	client := router.client(t, "comment-labels-owner")
+	client.ownerMarker = ownerMarker("webhook")
+	mikrotikProvider := &MikrotikProvider{
+		clients:      []*MikrotikApiClient{client},
+		defaults:     &MikrotikDefaults{DefaultTTL: 3600},
+		domainFilter: endpoint.NewDomainFilter([]string{"example.com"}),
+		config:       &MikrotikProviderConfig{CommentLabels: true, OwnerID: "webhook"},
+	}
+
+	// the noop registry sets no owner label, so the created record is stamped with the owner ID of the webhook
+	err := mikrotikProvider.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("b.example.com", "A", 3600, "192.0.2.2")},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if comment := router.comments()["b.example.com"]; comment != "[external-dns:webhook] edns:o=webhook" {
+		t.Errorf("Expected the created record to be stamped with the owner ID, got %q", comment)
+	}
+
+	endpoints, err := mikrotikProvider.Records(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to read records: %v", err)
+	}
+	if len(endpoints) != 1 || endpoints[0].DNSName != "b.example.com" || endpoints[0].Labels["owner"] != "webhook" {
+		t.Errorf("Expected only the record of the webhook to be read, with its owner, got %v", endpoints)
+	}
+}
+
+func TestMigrateTXTRegistry(t *testing.T) {
+	registryText := func(owner, resource string) string {
+		return `"heritage=external-dns,external-dns/owner=` + owner + `,external-dns/resource=` + resource + `"`
+	}
+
+	router := newMockRouter(t,
+		DNSRecord{ID: "*1", Name: "a.example.com", Address: "192.0.2.1", TTL: "1h", Comment: "web"},
+		DNSRecord{ID: "*2", Name: "a-a.example.com", Type: "TXT", Text: registryText("default", "ingress/default/a"), TTL: "1h"},
+		DNSRecord{ID: "*3", Name: "b.example.com", Type: "CNAME", CName: "a.example.com", TTL: "1h"},
+		DNSRecord{ID: "*4", Name: "b.example.com", Type: "TXT", Text: registryText("default", "service/default/b"), TTL: "1h"},
+		DNSRecord{ID: "*5", Name: "c.example.com", Address: "192.0.2.3", TTL: "1h"},
+		DNSRecord{ID: "*6", Name: "a-c.example.com", Type: "TXT", Text: registryText("other", "ingress/other/c"), TTL: "1h"},
+		DNSRecord{ID: "*7", Name: "a-d.example.com", Type: "TXT", Text: registryText("default", "ingress/default/d"), TTL: "1h"},
+	)
+	mikrotikProvider := &MikrotikProvider{
+		clients:      []*MikrotikApiClient{router.client(t, "migrate-txt-registry")},
+		defaults:     &MikrotikDefaults{DefaultTTL: 3600},
+		domainFilter: endpoint.NewDomainFilter([]string{"example.com"}),
+		config:       &MikrotikProviderConfig{CommentLabels: true, MigrateTXTRegistry: true, TXTOwnerID: "default"},
+	}
+
+	endpoints, err := mikrotikProvider.Records(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to read records: %v", err)
+	}
+
+	labels := map[string]endpoint.Labels{}
+	for _, ep := range endpoints {
+		labels[ep.DNSName+" "+ep.RecordType] = ep.Labels
+	}
+	if labels["a.example.com A"]["resource"] != "ingress/default/a" || labels["b.example.com CNAME"]["resource"] != "service/default/b" {
+		t.Errorf("Expected the labels of the TXT registry to be moved to the records, got %v", labels)
+	}
+	if len(labels["c.example.com A"]) != 0 {
+		t.Errorf("Expected the TXT registry entries of other owners to be ignored, got %v", labels["c.example.com A"])
+	}
+
+	comments := router.comments()
+	if comments["a.example.com"] != "web edns:o=default;r=ingress/default/a" {
+		t.Errorf("Expected the comment of the record to be kept, got %q", comments["a.example.com"])
+	}
+	for _, name := range []string{"a-a.example.com", "b.example.com"} {
+		if _, ok := labels[name+" TXT"]; ok {
+			t.Errorf("Expected the migrated TXT registry entry %s to be deleted", name)
+		}
+	}
+	for _, name := range []string{"a-c.example.com", "a-d.example.com"} {
+		if _, ok := labels[name+" TXT"]; !ok {
+			t.Errorf("Expected the TXT registry entry %s to be kept", name)
+		}
+	}
+}
+
+func TestMigrateTXTRegistryDryRun(t *testing.T) {
+	router := newMockRouter(t,
+		DNSRecord{ID: "*1", Name: "a.example.com", Address: "192.0.2.1", TTL: "1h"},
+		DNSRecord{ID: "*2", Name: "a-a.example.com", Type: "TXT", Text: `"heritage=external-dns,external-dns/owner=default"`, TTL: "1h"},
+	)
+	client := router.client(t, "migrate-txt-registry-dry-run")
+	client.dryRun = &dryRunRecorder{}
+	mikrotikProvider := &MikrotikProvider{
+		clients:      []*MikrotikApiClient{client},
+		defaults:     &MikrotikDefaults{DefaultTTL: 3600},
+		domainFilter: endpoint.NewDomainFilter([]string{"example.com"}),
+		config:       &MikrotikProviderConfig{CommentLabels: true, MigrateTXTRegistry: true, TXTOwnerID: "default", DryRun: true},
+	}
+
+	if _, err := mikrotikProvider.Records(context.Background()); err != nil {
+		t.Fatalf("Failed to read records: %v", err)
+	}
+	if operations := client.dryRun.operations; len(operations) != 0 {
+		t.Errorf("Expected the migration not to be recorded in dry-run mode, got %v", operations)
+	}
+	if mikrotikProvider.migrated["migrate-txt-registry-dry-run"] {
+		t.Errorf("Expected the router not to be flagged as migrated")
+	}
+}
@@ -0,0 +1,164 @@
+package mikrotik
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	activeURLGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "mikrotik",
+		Name:      "api_active_url",
+		Help:      "Whether the API URL is the one currently used to talk to the router (1) or not (0).",
+	}, []string{"router", "url"})
+
+	circuitBreakerStateGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "mikrotik",
+		Name:      "circuit_breaker_state",
+		Help:      "State of the circuit breaker of the router: closed (0), open (1) or half-open (2).",
+	}, []string{"router"})
+
+	retriesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "mikrotik",
+		Name:      "api_retries_total",
+		Help:      "Number of requests to the router that were sent again after failing.",
+	}, []string{"router"})
+
+	apiRequestsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "mikrotik",
+		Name:      "api_requests_total",
+		Help:      "Number of requests sent to the router, by method, path template and status.",
+	}, []string{"router", "method", "path", "status"})
+
+	apiRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "mikrotik",
+		Name:      "api_request_duration_seconds",
+		Help:      "Latency of the requests sent to the router, by method and path template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"router", "method", "path"})
+
+	recordsGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "mikrotik",
+		Name:      "records",
+		Help:      "Number of static DNS entries managed on the router, by record type, as of the last sync.",
+	}, []string{"router", "type"})
+
+	applyChangesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "mikrotik",
+		Name:      "apply_changes_total",
+		Help:      "Number of times changes were applied on the router, by result (success or failure).",
+	}, []string{"router", "result"})
+
+	appliedChangesHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "mikrotik",
+		Name:      "apply_changes_records",
+		Help:      "Number of static DNS entries created, updated and deleted on the router per successful ApplyChanges call.",
+		Buckets:   []float64{0, 1, 2, 5, 10, 20, 50, 100, 200, 500},
+	}, []string{"router", "action"})
+
+	inconsistentRecordsGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "mikrotik",
+		Name:      "inconsistent_records",
+		Help:      "Number of static DNS entries whose TTL, comment or flags differ from the other entries of the same endpoint, as of the last sync.",
+	}, []string{"router"})
+
+	divergedGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "mikrotik",
+		Name:      "records_diverged",
+		Help:      "Whether the records of the router diverged from the other routers (1) or not (0), as of the last sync.",
+	}, []string{"router"})
+
+	conversionFailuresCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "mikrotik",
+		Name:      "record_conversion_failures_total",
+		Help:      "Number of static DNS entries skipped because they could not be converted to an external-dns endpoint.",
+	}, []string{"router"})
+
+	lastSyncGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "mikrotik",
+		Name:      "last_successful_sync_timestamp_seconds",
+		Help:      "Unix timestamp of the last sync of the router in which both reading its records and applying the changes succeeded.",
+	}, []string{"router"})
+)
+
+// instrumentedTransport records the requests sent through a transport in the API metrics.
+// Requests are labelled with the REST API method and path they map to, whatever the underlying protocol, with item IDs
+// replaced by a placeholder to keep the number of label values bounded.
+type instrumentedTransport struct {
+	transport
+	router string
+}
+
+func (t *instrumentedTransport) get(ctx context.Context, path string, query url.Values, out any) error {
+	return t.observe(ctx, "GET", path, func(ctx context.Context) error { return t.transport.get(ctx, path, query, out) })
+}
+
+func (t *instrumentedTransport) add(ctx context.Context, path string, item any, out any) error {
+	return t.observe(ctx, "PUT", path, func(ctx context.Context) error { return t.transport.add(ctx, path, item, out) })
+}
+
+func (t *instrumentedTransport) set(ctx context.Context, path, id string, fields any, out any) error {
+	return t.observe(ctx, "PATCH", path+"/{id}", func(ctx context.Context) error { return t.transport.set(ctx, path, id, fields, out) })
+}
+
+func (t *instrumentedTransport) remove(ctx context.Context, path, id string) error {
+	return t.observe(ctx, "DELETE", path+"/{id}", func(ctx context.Context) error { return t.transport.remove(ctx, path, id) })
+}
+
+// observe runs a request and records its outcome and latency
+func (t *instrumentedTransport) observe(ctx context.Context, method, path string, fn func(ctx context.Context) error) error {
+	status := new(int)
+	start := time.Now()
+	err := fn(context.WithValue(ctx, responseStatusKey{}, status))
+	apiRequestDuration.WithLabelValues(t.router, method, path).Observe(time.Since(start).Seconds())
+	apiRequestsCounter.WithLabelValues(t.router, method, path, requestStatus(*status, err)).Inc()
+	return err
+}
+
+// responseStatusKey is the context key of the status code of a successful response, reported by the transport
+type responseStatusKey struct{}
+
+// reportResponseStatus reports the status code of a successful response to the instrumentation of the request, if any
+func reportResponseStatus(ctx context.Context, statusCode int) {
+	if status, ok := ctx.Value(responseStatusKey{}).(*int); ok {
+		*status = statusCode
+	}
+}
+
+// requestStatus returns the status label of a request that completed with the given error: the HTTP status code of
+// REST API responses, "done" or "trap" for the replies of the binary API, "timeout" or "canceled" for aborted requests
+// and "error" for other failures, like unreachable routers. The status code of successful responses is the one
+// reported by the transport.
+func requestStatus(statusCode int, err error) string {
+	if err == nil {
+		if statusCode == 0 {
+			return "done"
+		}
+		return strconv.Itoa(statusCode)
+	}
+
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		return strconv.Itoa(reqErr.StatusCode)
+	}
+
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return "trap"
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,171 @@
+// metrics_test.go
+package mikrotik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestRequestStatus(t *testing.T) {
+	testCases := []struct {
+		name       string
+		statusCode int
+		err        error
+		expected   string
+	}{
+		{name: "REST API success", statusCode: 200, err: nil, expected: "200"},
+		{name: "Binary API success", err: nil, expected: "done"},
+		{name: "REST API error", err: &requestError{StatusCode: 404, Status: "404 Not Found"}, expected: "404"},
+		{name: "Wrapped REST API error", err: fmt.Errorf("all API URLs failed: %w", &requestError{StatusCode: 503}), expected: "503"},
+		{name: "Binary API trap", err: &apiError{Message: "no such item"}, expected: "trap"},
+		{name: "Timeout", err: fmt.Errorf("command aborted: %w", context.DeadlineExceeded), expected: "timeout"},
+		{name: "Cancelled", err: context.Canceled, expected: "canceled"},
+		{name: "Unreachable", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, expected: "error"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if status := requestStatus(tc.statusCode, tc.err); status != tc.expected {
+				t.Errorf("Expected status %s, got %s", tc.expected, status)
+			}
+		})
+	}
+}
+
+func TestRequestMetrics(t *testing.T) {
+	router := newMockRouter(t, DNSRecord{ID: "*1", Name: "a.example.com", Address: "192.0.2.1"})
+	client := router.client(t, "metrics-requests")
+
+	if _, err := client.GetAllDNSRecords(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := client.RemoveDNSRecord(context.Background(), &DNSRecord{ID: "*1"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := client.RemoveDNSRecord(context.Background(), &DNSRecord{ID: "*1"}); err == nil {
+		t.Fatalf("Expected error, got none")
+	}
+
+	testCases := []struct {
+		method, path, status string
+		expected             float64
+	}{
+		{method: "GET", path: "ip/dns/static", status: "200", expected: 1},
+		{method: "DELETE", path: "ip/dns/static/{id}", status: "204", expected: 1},
+		{method: "DELETE", path: "ip/dns/static/{id}", status: "404", expected: 1},
+	}
+	for _, tc := range testCases {
+		counter := apiRequestsCounter.WithLabelValues("metrics-requests", tc.method, tc.path, tc.status)
+		if count := testutil.ToFloat64(counter); count != tc.expected {
+			t.Errorf("Expected %v %s %s requests with status %s, got %v", tc.expected, tc.method, tc.path, tc.status, count)
+		}
+	}
+
+	latencies := []struct {
+		method, path string
+		expected     uint64
+	}{
+		{method: "GET", path: "ip/dns/static", expected: 1},
+		{method: "DELETE", path: "ip/dns/static/{id}", expected: 2},
+	}
+	for _, tc := range latencies {
+		metric := &dto.Metric{}
+		if err := apiRequestDuration.WithLabelValues("metrics-requests", tc.method, tc.path).(prometheus.Histogram).Write(metric); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if count := metric.GetHistogram().GetSampleCount(); count != tc.expected {
+			t.Errorf("Expected %d latencies of %s %s requests, got %d", tc.expected, tc.method, tc.path, count)
+		}
+	}
+}
+
+func TestSyncMetrics(t *testing.T) {
+	router := newMockRouter(t,
+		DNSRecord{ID: "*1", Name: "a.example.com", Address: "192.0.2.1"},
+		DNSRecord{ID: "*2", Name: "b.example.com", Address: "192.0.2.2"},
+		DNSRecord{ID: "*3", Name: "c.example.com", Type: "CNAME", CName: "a.example.com"},
+		DNSRecord{ID: "*4", Name: "d.example.com", Address: "192.0.2.4", TTL: "bogus"},
+		DNSRecord{ID: "*5", Name: "other.org", Address: "192.0.2.5"},
+	)
+
+	const name = "metrics-sync"
+	mikrotikProvider := &MikrotikProvider{
+		clients:      []*MikrotikApiClient{router.client(t, name)},
+		defaults:     &MikrotikDefaults{DefaultTTL: 3600},
+		domainFilter: endpoint.NewDomainFilter([]string{"example.com"}),
+	}
+
+	if _, err := mikrotikProvider.Records(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if count := testutil.ToFloat64(recordsGauge.WithLabelValues(name, "A")); count != 2 {
+		t.Errorf("Expected 2 A records, got %v", count)
+	}
+	if count := testutil.ToFloat64(recordsGauge.WithLabelValues(name, "CNAME")); count != 1 {
+		t.Errorf("Expected 1 CNAME record, got %v", count)
+	}
+	if count := testutil.ToFloat64(conversionFailuresCounter.WithLabelValues(name)); count != 1 {
+		t.Errorf("Expected 1 conversion failure, got %v", count)
+	}
+	lastSync := testutil.ToFloat64(lastSyncGauge.WithLabelValues(name))
+	if lastSync == 0 {
+		t.Errorf("Expected the last sync timestamp to be set")
+	}
+
+	// a failed ApplyChanges stops the sync from being successful, even if reading the records still works
+	router.setRejectAddress("192.0.2.9")
+	err := mikrotikProvider.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("e.example.com", "A", "192.0.2.9")},
+	})
+	if err == nil {
+		t.Fatalf("Expected error, got none")
+	}
+	lastSyncGauge.WithLabelValues(name).Set(0)
+	if _, err := mikrotikProvider.Records(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if value := testutil.ToFloat64(lastSyncGauge.WithLabelValues(name)); value != 0 {
+		t.Errorf("Expected the last sync timestamp not to be updated after a failed ApplyChanges")
+	}
+
+	err = mikrotikProvider.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("e.example.com", "A", "192.0.2.5", "192.0.2.6")},
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("b.example.com", "A", "192.0.2.2")},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if value := testutil.ToFloat64(lastSyncGauge.WithLabelValues(name)); value == 0 {
+		t.Errorf("Expected the last sync timestamp to be updated after a successful ApplyChanges")
+	}
+	if count := testutil.ToFloat64(applyChangesCounter.WithLabelValues(name, "failure")); count != 1 {
+		t.Errorf("Expected 1 failed ApplyChanges, got %v", count)
+	}
+	if count := testutil.ToFloat64(applyChangesCounter.WithLabelValues(name, "success")); count != 1 {
+		t.Errorf("Expected 1 successful ApplyChanges, got %v", count)
+	}
+
+	for action, expected := range map[string]float64{"create": 2, "delete": 1, "update": 0} {
+		if sum := histogramSum(t, appliedChangesHistogram.WithLabelValues(name, action)); sum != expected {
+			t.Errorf("Expected %v records to be changed by %s, got %v", expected, action, sum)
+		}
+	}
+}
+
+// histogramSum returns the sum of the values observed by a histogram
+func histogramSum(t *testing.T, observer prometheus.Observer) float64 {
+	metric := &dto.Metric{}
+	if err := observer.(prometheus.Metric).Write(metric); err != nil {
+		t.Fatalf("Failed to read histogram: %v", err)
+	}
+	return metric.GetHistogram().GetSampleSum()
+}
@@ -0,0 +1,93 @@
+package mikrotik
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// ApplyOrder is the order in which the changes of a sync are applied on a router
+type ApplyOrder string
+
+const (
+	// ApplyOrderBreakBeforeMake deletes the records of a sync before creating the new ones
+	ApplyOrderBreakBeforeMake ApplyOrder = "break-before-make"
+	// ApplyOrderMakeBeforeBreak creates the records of a sync before deleting the old ones, so that names keep resolving
+	// while their records are replaced. Records that cannot co-exist with the deleted ones are still created after them.
+	ApplyOrderMakeBeforeBreak ApplyOrder = "make-before-break"
+)
+
+// validateApplyOrder checks that the apply order is a known one. An empty order is the default, break-before-make.
+func validateApplyOrder(order ApplyOrder) error {
+	switch order {
+	case "", ApplyOrderBreakBeforeMake, ApplyOrderMakeBeforeBreak:
+		return nil
+	}
+	return fmt.Errorf("unknown apply order %q, expected %q or %q", order, ApplyOrderBreakBeforeMake, ApplyOrderMakeBeforeBreak)
+}
+
+// makeBeforeBreak checks if records are created before the old ones are deleted
+func (p *MikrotikProvider) makeBeforeBreak() bool {
+	return p.config != nil && p.config.ApplyOrder == ApplyOrderMakeBeforeBreak
+}
+
+// earlyCreates splits the endpoints to create into the ones created before the deletes, and the ones created after
+// them. In make-before-break order, endpoints are created early unless their records cannot co-exist with the ones of
+// an endpoint to delete, or they are CNAME records pointing to a name created late. Otherwise, all of them are created
+// after the deletes.
+func (p *MikrotikProvider) earlyCreates(deletes, creates []*endpoint.Endpoint) ([]*endpoint.Endpoint, []*endpoint.Endpoint) {
+	if !p.makeBeforeBreak() {
+		return []*endpoint.Endpoint{}, creates
+	}
+
+	early := []*endpoint.Endpoint{}
+	late := []*endpoint.Endpoint{}
+	lateNames := map[string]bool{}
+	for _, create := range creates {
+		conflicting := slices.ContainsFunc(deletes, func(del *endpoint.Endpoint) bool {
+			return conflictingEndpoints(create, del)
+		})
+		if create.RecordType == "CNAME" {
+			conflicting = conflicting || slices.ContainsFunc(create.Targets, func(target string) bool {
+				return lateNames[normalizeDomain(target)]
+			})
+		}
+
+		if conflicting {
+			late = append(late, create)
+			lateNames[normalizeDomain(create.DNSName)] = true
+		} else {
+			early = append(early, create)
+		}
+	}
+	return early, late
+}
+
+// conflictingEndpoints checks if the records of two endpoints cannot be on a router at the same time. A CNAME cannot
+// co-exist with other records of the same name, and the same entry cannot be created before it is deleted, as deleting
+// it would match both entries.
+func conflictingEndpoints(a, b *endpoint.Endpoint) bool {
+	if !strings.EqualFold(a.DNSName, b.DNSName) {
+		return false
+	}
+	if a.RecordType == "CNAME" || b.RecordType == "CNAME" {
+		return true
+	}
+	if a.RecordType != b.RecordType {
+		return false
+	}
+	if !hasTarget(a.RecordType) {
+		return true
+	}
+
+	for _, aTarget := range a.Targets {
+		for _, bTarget := range b.Targets {
+			if sameTarget(aTarget, bTarget) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package mikrotik
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestConflictingEndpoints(t *testing.T) {
+	testCases := []struct {
+		name     string
+		a        *endpoint.Endpoint
+		b        *endpoint.Endpoint
+		expected bool
+	}{
+		{name: "Other names", a: endpoint.NewEndpoint("a.example.com", "CNAME", "b.example.com"), b: endpoint.NewEndpoint("b.example.com", "A", "192.0.2.1"), expected: false},
+		{name: "Other targets", a: endpoint.NewEndpoint("a.example.com", "A", "192.0.2.2"), b: endpoint.NewEndpoint("a.example.com", "A", "192.0.2.1"), expected: false},
+		{name: "Other types", a: endpoint.NewEndpoint("a.example.com", "AAAA", "2001:db8::1"), b: endpoint.NewEndpoint("a.example.com", "A", "192.0.2.1"), expected: false},
+		{name: "Same target", a: endpoint.NewEndpoint("A.example.com", "A", "192.0.2.1", "192.0.2.2"), b: endpoint.NewEndpoint("a.example.com", "A", "192.0.2.1"), expected: true},
+		{name: "CNAME beside other types", a: endpoint.NewEndpoint("a.example.com", "CNAME", "b.example.com"), b: endpoint.NewEndpoint("a.example.com", "A", "192.0.2.1"), expected: true},
+		{name: "CNAME with another target", a: endpoint.NewEndpoint("a.example.com", "CNAME", "b.example.com"), b: endpoint.NewEndpoint("a.example.com", "CNAME", "c.example.com"), expected: true},
+		{name: "Types without target", a: endpoint.NewEndpoint("a.example.com", "NXDOMAIN"), b: endpoint.NewEndpoint("a.example.com", "NXDOMAIN"), expected: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if conflicting := conflictingEndpoints(tc.a, tc.b); conflicting != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, conflicting)
+			}
+		})
+	}
+}
+
+func TestApplyOrder(t *testing.T) {
+	testCases := []struct {
+		name     string
+		order    ApplyOrder
+		changes  *plan.Changes
+		expected []string
+	}{
+		{
+			name:  "Break-before-make deletes first",
+			order: ApplyOrderBreakBeforeMake,
+			changes: &plan.Changes{
+				UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.example.com", "A", 3600, "192.0.2.1")},
+				UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.example.com", "AAAA", 3600, "2001:db8::1")},
+			},
+			expected: []string{"DELETE a.example.com A", "PUT a.example.com AAAA"},
+		},
+		{
+			name:  "Make-before-break creates first",
+			order: ApplyOrderMakeBeforeBreak,
+			changes: &plan.Changes{
+				UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.example.com", "A", 3600, "192.0.2.1")},
+				UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.example.com", "AAAA", 3600, "2001:db8::1")},
+			},
+			expected: []string{"PUT a.example.com AAAA", "DELETE a.example.com A"},
+		},
+		{
+			name:  "Make-before-break deletes first for conflicting types",
+			order: ApplyOrderMakeBeforeBreak,
+			changes: &plan.Changes{
+				Create:    []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("b.example.com", "A", 3600, "192.0.2.2")},
+				UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.example.com", "A", 3600, "192.0.2.1")},
+				UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.example.com", "CNAME", 3600, "b.example.com")},
+			},
+			expected: []string{"PUT b.example.com A", "DELETE a.example.com A", "PUT a.example.com CNAME"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := newMockRouter(t, DNSRecord{ID: "*1", Name: "a.example.com", Address: "192.0.2.1", TTL: "1h"})
+			mikrotikProvider := &MikrotikProvider{
+				clients:  []*MikrotikApiClient{router.client(t, "router")},
+				defaults: &MikrotikDefaults{DefaultTTL: 3600},
+				config:   &MikrotikProviderConfig{ApplyOrder: tc.order},
+			}
+
+			if err := mikrotikProvider.ApplyChanges(context.Background(), tc.changes); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if writes := router.writeLog(); !slices.Equal(writes, tc.expected) {
+				t.Errorf("Expected requests %v, got %v", tc.expected, writes)
+			}
+		})
+	}
+}
+
+func TestValidateApplyOrder(t *testing.T) {
+	for _, order := range []ApplyOrder{"", ApplyOrderBreakBeforeMake, ApplyOrderMakeBeforeBreak} {
+		if err := validateApplyOrder(order); err != nil {
+			t.Errorf("Expected order %q to be valid, got %v", order, err)
+		}
+	}
+	if err := validateApplyOrder("creates-first"); err == nil {
+		t.Errorf("Expected an unknown order to be invalid")
+	}
+}
@@ -0,0 +1,91 @@
+package mikrotik
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotOwned is returned when a record that would have to be changed or deleted was not created by this webhook
+var ErrNotOwned = errors.New("record is not managed by this webhook")
+
+// ownerMarker returns the tag marking the records created by the webhook with the given owner ID, in their comment
+func ownerMarker(ownerID string) string {
+	return fmt.Sprintf("[external-dns:%s]", ownerID)
+}
+
+// withMarker returns the comment with the marker in front of it, unless it is already marked.
+// The marker is separated from the rest of the comment by a space.
+func withMarker(comment, marker string) string {
+	if hasMarker(comment, marker) {
+		return comment
+	}
+	if comment == "" {
+		return marker
+	}
+	return marker + " " + comment
+}
+
+// withoutMarker returns the comment without the marker in front of it
+func withoutMarker(comment, marker string) string {
+	if !hasMarker(comment, marker) {
+		return comment
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(comment, marker), " ")
+}
+
+// hasMarker checks if the comment starts with the marker, on its own or followed by a space
+func hasMarker(comment, marker string) bool {
+	rest, found := strings.CutPrefix(comment, marker)
+	return found && (rest == "" || strings.HasPrefix(rest, " "))
+}
+
+// ownsRecord checks if the record was created by the webhook. Without an owner ID, all records are considered owned.
+func (c *MikrotikApiClient) ownsRecord(record *DNSRecord) bool {
+	return c.ownerMarker == "" || hasMarker(record.Comment, c.ownerMarker)
+}
+
+// markOwned returns a copy of the record with the owner marker added to its comment
+func (c *MikrotikApiClient) markOwned(record *DNSRecord) *DNSRecord {
+	marked := *record
+	if c.ownerMarker != "" {
+		marked.Comment = withMarker(record.Comment, c.ownerMarker)
+	}
+	return &marked
+}
+
+// unmarkOwned returns a copy of the record with the owner marker removed from its comment, as exposed to ExternalDNS
+func (c *MikrotikApiClient) unmarkOwned(record *DNSRecord) *DNSRecord {
+	unmarked := *record
+	if c.ownerMarker != "" {
+		unmarked.Comment = withoutMarker(record.Comment, c.ownerMarker)
+	}
+	return &unmarked
+}
+
+// matchOwnedDNSRecord returns the only record owned by the webhook with the same identity as the wanted one, or nil
+// if there is none. If only records that are not owned match, it fails with ErrNotOwned rather than touching them.
+func (c *MikrotikApiClient) matchOwnedDNSRecord(records []DNSRecord, wanted *DNSRecord, compareTarget bool) (*DNSRecord, error) {
+	if c.ownerMarker == "" {
+		return matchDNSRecord(records, wanted, compareTarget)
+	}
+
+	var owned []DNSRecord
+	for _, record := range records {
+		if c.ownsRecord(&record) {
+			owned = append(owned, record)
+		}
+	}
+
+	record, err := matchDNSRecord(owned, wanted, compareTarget)
+	if record != nil || err != nil {
+		return record, err
+	}
+
+	for _, record := range records {
+		if record.sameIdentity(wanted, compareTarget) {
+			return nil, fmt.Errorf("refusing to change record %s %s (%s): %w", defaultValue(record.Name, record.Regexp), defaultValue(record.Type, "A"), record.ID, ErrNotOwned)
+		}
+	}
+	return nil, nil
+}
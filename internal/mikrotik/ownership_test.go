@@ -0,0 +1,98 @@
+package mikrotik
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestOwnerMarker(t *testing.T) {
+	marker := ownerMarker("cluster-1")
+
+	testCases := []struct {
+		name     string
+		comment  string
+		owned    bool
+		marked   string
+		unmarked string
+	}{
+		{name: "Empty comment", comment: "", owned: false, marked: "[external-dns:cluster-1]", unmarked: ""},
+		{name: "User comment", comment: "hand-made", owned: false, marked: "[external-dns:cluster-1] hand-made", unmarked: "hand-made"},
+		{name: "Marker only", comment: "[external-dns:cluster-1]", owned: true, marked: "[external-dns:cluster-1]", unmarked: ""},
+		{name: "Marker and user comment", comment: "[external-dns:cluster-1] web", owned: true, marked: "[external-dns:cluster-1] web", unmarked: "web"},
+		{name: "Other owner", comment: "[external-dns:cluster-10] web", owned: false, marked: "[external-dns:cluster-1] [external-dns:cluster-10] web", unmarked: "[external-dns:cluster-10] web"},
+		{name: "Marker not in front", comment: "web [external-dns:cluster-1]", owned: false, marked: "[external-dns:cluster-1] web [external-dns:cluster-1]", unmarked: "web [external-dns:cluster-1]"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if owned := hasMarker(tc.comment, marker); owned != tc.owned {
+				t.Errorf("Expected owned to be %v, got %v", tc.owned, owned)
+			}
+			if marked := withMarker(tc.comment, marker); marked != tc.marked {
+				t.Errorf("Expected marked comment %q, got %q", tc.marked, marked)
+			}
+			if unmarked := withoutMarker(tc.comment, marker); unmarked != tc.unmarked {
+				t.Errorf("Expected unmarked comment %q, got %q", tc.unmarked, unmarked)
+			}
+		})
+	}
+}
+
+func TestOwnership(t *testing.T) {
+	router := newMockRouter(t,
+		DNSRecord{ID: "*1", Name: "a.example.com", Address: "192.0.2.1", TTL: "1h", Comment: "[external-dns:cluster-1] web"},
+		DNSRecord{ID: "*2", Name: "b.example.com", Address: "192.0.2.2", TTL: "1h", Comment: "hand-made"},
+		DNSRecord{ID: "*3", Name: "c.example.com", Address: "192.0.2.3", TTL: "1h"},
+	)
+
+	configs := []*MikrotikConnectionConfig{{Name: "ownership", BaseUrls: []string{router.URL}, SkipTLSVerify: true}}
+	p, err := NewMikrotikProvider(endpoint.NewDomainFilter([]string{"example.com"}), &MikrotikDefaults{DefaultTTL: 3600}, configs, &MikrotikProviderConfig{OwnerID: "cluster-1"})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	// only marked records are exposed, without the marker
+	endpoints, err := p.Records(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to read records: %v", err)
+	}
+	if len(endpoints) != 1 || endpoints[0].DNSName != "a.example.com" {
+		t.Fatalf("Expected only a.example.com to be exposed, got %v", endpoints)
+	}
+	if comment, _ := endpoints[0].GetProviderSpecificProperty("comment"); comment != "web" {
+		t.Errorf("Expected the comment without the marker, got %q", comment)
+	}
+
+	// created records are marked, and updates keep the marker
+	err = p.ApplyChanges(context.Background(), &plan.Changes{
+		Create:    []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("d.example.com", "A", 3600, "192.0.2.4")},
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.example.com", "A", 3600, "192.0.2.1").WithProviderSpecific("comment", "web")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.example.com", "A", 3600, "192.0.2.1").WithProviderSpecific("comment", "api")},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	comments := router.comments()
+	if comments["a.example.com"] != "[external-dns:cluster-1] api" {
+		t.Errorf("Expected the updated record to keep the marker, got %q", comments["a.example.com"])
+	}
+	if comments["d.example.com"] != "[external-dns:cluster-1]" {
+		t.Errorf("Expected the created record to be marked, got %q", comments["d.example.com"])
+	}
+
+	// records that are not marked are never deleted
+	err = p.ApplyChanges(context.Background(), &plan.Changes{
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("b.example.com", "A", 3600, "192.0.2.2")},
+	})
+	if !errors.Is(err, ErrNotOwned) {
+		t.Errorf("Expected not owned error, got %v", err)
+	}
+	if addresses := router.addresses(); !slices.Contains(addresses, "192.0.2.2") {
+		t.Errorf("Expected the hand-made record to be kept, got %v", addresses)
+	}
+}
@@ -2,91 +2,692 @@ package mikrotik
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 	"sigs.k8s.io/external-dns/endpoint"
 	"sigs.k8s.io/external-dns/plan"
 	"sigs.k8s.io/external-dns/provider"
 )
 
+// MikrotikProviderConfig holds the settings that change how the provider manages records
+type MikrotikProviderConfig struct {
+	RegexpNameSuffix string `env:"MIKROTIK_REGEXP_NAME_SUFFIX" envDefault:""`
+
+	// How often the routers are checked in the background for the readiness probe, 0 to only check them at startup
+	HealthCheckInterval time.Duration `env:"MIKROTIK_HEALTH_CHECK_INTERVAL" envDefault:"30s"`
+
+	// In ownership mode, only the records whose comment is tagged with the owner ID are managed, i.e. [external-dns:<id>]
+	OwnerID string `env:"MIKROTIK_OWNER_ID" envDefault:""`
+
+	// Labels of the endpoints (i.e. owner and resource) are stored in the comment of the records instead of TXT records,
+	// so that external-dns can run with the noop registry
+	CommentLabels bool `env:"MIKROTIK_COMMENT_LABELS" envDefault:"false"`
+
+	// Before the records of a router are first read, the TXT registry entries matching the TXT registry settings of
+	// external-dns are folded into the comment of the records they own, and deleted
+	MigrateTXTRegistry bool   `env:"MIKROTIK_MIGRATE_TXT_REGISTRY" envDefault:"false"`
+	TXTOwnerID         string `env:"MIKROTIK_TXT_OWNER_ID" envDefault:""`
+	TXTPrefix          string `env:"MIKROTIK_TXT_PREFIX" envDefault:""`
+	TXTSuffix          string `env:"MIKROTIK_TXT_SUFFIX" envDefault:""`
+
+	// In dry-run mode, records are read from the routers but changes are only logged and reported, not applied
+	DryRun bool `env:"MIKROTIK_DRY_RUN" envDefault:"false"`
+
+	// How often the connection to routers that could not be reached at startup is retried
+	ConnectRetryInterval time.Duration `env:"MIKROTIK_CONNECT_RETRY_INTERVAL" envDefault:"10s"`
+
+	// Whether the records of a sync are deleted before the new ones are created, or the other way around
+	ApplyOrder ApplyOrder `env:"MIKROTIK_APPLY_ORDER" envDefault:"break-before-make"`
+
+	// The records a diverged router misses are created on it by the next sync. Requires ownership mode, so that only the
+	// records created by the webhook are copied between routers.
+	RepairDivergedRouters bool `env:"MIKROTIK_REPAIR_DIVERGED_ROUTERS" envDefault:"false"`
+}
+
 // DNS Provider for working with mikrotik
 type MikrotikProvider struct {
 	provider.BaseProvider
 
-	client       *MikrotikApiClient
+	clients      []*MikrotikApiClient
+	defaults     *MikrotikDefaults
 	domainFilter *endpoint.DomainFilter
+	config       *MikrotikProviderConfig
+
+	mu         sync.Mutex
+	diverged   map[string]bool
+	missing    map[string][]*endpoint.Endpoint
+	records    []*endpoint.Endpoint
+	health     map[string]*routerHealth
+	lastDryRun *DryRunPlan
+	migrated   map[string]bool
 }
 
-// NewMikrotikProvider initializes a new DNSProvider, of the Mikrotik variety
-func NewMikrotikProvider(domainFilter *endpoint.DomainFilter, defaults *MikrotikDefaults, config *MikrotikConnectionConfig) (provider.Provider, error) {
-	// Create the Mikrotik API Client
-	client, err := NewMikrotikClient(config, defaults)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create the MikroTik client: %w", err)
+// NewMikrotikProvider initializes a new DNSProvider, of the Mikrotik variety, managing the records on all of the given routers.
+// Routers that cannot be reached do not keep the provider from starting: the connection is retried in the background and
+// the provider is not ready until all routers are connected.
+func NewMikrotikProvider(domainFilter *endpoint.DomainFilter, defaults *MikrotikDefaults, configs []*MikrotikConnectionConfig, providerConfig *MikrotikProviderConfig) (provider.Provider, error) {
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("no MikroTik routers configured")
+	}
+	if providerConfig != nil && providerConfig.MigrateTXTRegistry && !providerConfig.CommentLabels {
+		return nil, fmt.Errorf("migrating the TXT registry requires labels to be stored in comments")
+	}
+	if providerConfig != nil && providerConfig.RepairDivergedRouters && providerConfig.OwnerID == "" {
+		return nil, fmt.Errorf("repairing diverged routers requires an owner ID")
+	}
+	if providerConfig != nil {
+		if err := validateApplyOrder(providerConfig.ApplyOrder); err != nil {
+			return nil, err
+		}
+	}
+	if defaults != nil && defaults.DefaultComment != "" {
+		if _, err := parseCommentTemplate(defaults.DefaultComment); err != nil {
+			return nil, err
+		}
 	}
 
-	// Ensure the Client can connect to the API by fetching system info
-	info, err := client.GetSystemInfo()
-	if err != nil {
-		log.Errorf("failed to connect to the MikroTik RouterOS API Endpoint: %v", err)
-		return nil, err
+	// Create the Mikrotik API Clients
+	clients := make([]*MikrotikApiClient, 0, len(configs))
+	for _, config := range configs {
+		client, err := NewMikrotikClient(config, defaults)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the MikroTik client for %s: %w", config.RouterName(), err)
+		}
+		clients = append(clients, client)
 	}
-	log.Infof("connected to board %s running RouterOS version %s (%s)", info.BoardName, info.Version, info.ArchitectureName)
 
-	// If the client connects properly, create the DNS Provider
 	p := &MikrotikProvider{
-		client:       client,
+		clients:      clients,
+		defaults:     defaults,
 		domainFilter: domainFilter,
+		config:       providerConfig,
+	}
+	if p.dryRunEnabled() {
+		log.Warnf("dry-run mode is enabled, changes will not be applied on the routers")
+		if providerConfig.MigrateTXTRegistry {
+			log.Warnf("the TXT registry is not migrated in dry-run mode")
+		}
+		for _, client := range clients {
+			client.dryRun = &dryRunRecorder{}
+		}
+	}
+	if providerConfig != nil && providerConfig.CommentLabels && providerConfig.OwnerID == "" {
+		log.Warnf("labels are stored in comments without an owner ID, the ownership of records is lost with the noop registry")
+	}
+	if providerConfig != nil && providerConfig.OwnerID != "" {
+		log.Infof("ownership mode is enabled, only managing records tagged with %s", ownerMarker(providerConfig.OwnerID))
+		for _, client := range clients {
+			client.ownerMarker = ownerMarker(providerConfig.OwnerID)
+		}
+	}
+
+	// Check that the Clients can connect to the API by fetching system info
+	errs := p.checkRouters(context.Background())
+	for i, client := range clients {
+		if errs[i] != nil {
+			log.Errorf("failed to connect to the MikroTik RouterOS API Endpoint of %s: %v", client.RouterName(), errs[i])
+		}
+	}
+	if err := routerErrors(clients, errs); err != nil {
+		if providerConfig == nil || providerConfig.ConnectRetryInterval <= 0 {
+			return nil, err
+		}
+		log.Warnf("starting without a connection to all routers, retrying every %v", providerConfig.ConnectRetryInterval)
+		go p.connect(context.Background(), providerConfig.ConnectRetryInterval)
+	}
+
+	if providerConfig != nil && providerConfig.HealthCheckInterval > 0 {
+		go p.runHealthChecks(context.Background(), providerConfig.HealthCheckInterval)
 	}
 
 	return p, nil
 }
 
 // Records returns the list of all DNS records.
+// The records of all routers are merged together and routers whose records diverge from the merged state are flagged.
 func (p *MikrotikProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
-	records, err := p.client.GetAllDNSRecords()
+	results := make([][]*endpoint.Endpoint, len(p.clients))
+	errs := make([]error, len(p.clients))
+
+	var wg sync.WaitGroup
+	for i, client := range p.clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = p.routerRecords(ctx, client)
+			p.recordRead(client, errs[i])
+		}()
+	}
+	wg.Wait()
+
+	if err := routerErrors(p.clients, errs); err != nil {
+		return nil, newProviderError(err)
+	}
+
+	return p.mergeRecords(results), nil
+}
+
+// routerRecords returns the list of DNS records on a single router.
+// Static entries sharing the same name and type are grouped into a single endpoint with multiple targets, with the
+// properties of the first entry. Entries whose TTL, comment or flags differ from it are reported, but left as they are.
+func (p *MikrotikProvider) routerRecords(ctx context.Context, client *MikrotikApiClient) ([]*endpoint.Endpoint, error) {
+	if p.txtMigrationEnabled() {
+		if err := p.migrateRouter(ctx, client); err != nil {
+			return nil, err
+		}
+	}
+
+	records, err := client.GetAllDNSRecords(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	var endpoints []*endpoint.Endpoint
+	grouped := map[string]*endpoint.Endpoint{}
+	firsts := map[string]DNSRecord{}
+	inconsistent := 0
+	counts := map[string]int{}
 	for _, record := range records {
-		ep, err := record.toExternalDNSEndpoint()
+		if !client.ownsRecord(&record) {
+			log.Debugf("Skipping record not managed by this webhook: %+v", record)
+			continue
+		}
+
+		ep, err := client.unmarkOwned(&record).toExternalDNSEndpoint()
 		if err != nil {
 			log.Warnf("Failed to convert mikrotik record to external-dns endpoint: %+v", err)
+			conversionFailuresCounter.WithLabelValues(client.RouterName()).Inc()
 			continue
 		}
+		if p.commentLabelsEnabled() {
+			p.readCommentLabels(ep)
+		}
+
+		if ep.DNSName == "" && p.regexpNamesEnabled() {
+			ep.DNSName = regexpRecordName(record.Regexp, p.config.RegexpNameSuffix)
+			log.Debugf("Exposing regexp record '%s' as: %s", record.Regexp, ep.DNSName)
+		}
 
 		if !p.domainFilter.Match(ep.DNSName) {
 			continue
 		}
+		counts[ep.RecordType]++
+
+		key := p.endpointKey(ep)
+		if existing, ok := grouped[key]; ok {
+			log.Debugf("Adding target %v to existing endpoint: %v", ep.Targets, existing)
+			existing.Targets = append(existing.Targets, ep.Targets...)
+
+			if !p.compareProperties(existing, ep) {
+				log.Warnf("Record %s of %s %s on %s has other properties than its first entry %s", record.ID, ep.DNSName, ep.RecordType, client.RouterName(), firsts[key].ID)
+				inconsistent++
+			}
+			continue
+		}
 
+		grouped[key] = ep
+		firsts[key] = record
 		endpoints = append(endpoints, ep)
 	}
 
+	recordsGauge.DeletePartialMatch(prometheus.Labels{"router": client.RouterName()})
+	for recordType, count := range counts {
+		recordsGauge.WithLabelValues(client.RouterName(), recordType).Set(float64(count))
+	}
+
+	inconsistentRecordsGauge.WithLabelValues(client.RouterName()).Set(float64(inconsistent))
+
 	return endpoints, nil
 }
 
-// ApplyChanges applies a given set of changes in the DNS provider.
+// mergeRecords merges the records of all routers into a single list.
+// Endpoints found on several routers point to the targets of all of them, with the properties of the first router they
+// were found on. Routers missing some of the merged records, or with different properties, are flagged as diverged.
+// When repairing diverged routers is enabled, the records they miss are created on them by the next ApplyChanges call.
+func (p *MikrotikProvider) mergeRecords(results [][]*endpoint.Endpoint) []*endpoint.Endpoint {
+	var merged []*endpoint.Endpoint
+	byKey := map[string]*endpoint.Endpoint{}
+	for _, endpoints := range results {
+		for _, ep := range endpoints {
+			existing, ok := byKey[p.endpointKey(ep)]
+			if !ok {
+				existing = withTargets(ep, slices.Clone(ep.Targets))
+				byKey[p.endpointKey(ep)] = existing
+				merged = append(merged, existing)
+				continue
+			}
+			existing.Targets = append(existing.Targets, targetsDifference(ep.Targets, existing.Targets)...)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.diverged == nil {
+		p.diverged = map[string]bool{}
+	}
+	p.records = merged
+	p.missing = map[string][]*endpoint.Endpoint{}
+	for i, endpoints := range results {
+		name := p.clients[i].RouterName()
+		own := map[string]*endpoint.Endpoint{}
+		diverged := false
+		for _, ep := range endpoints {
+			own[p.endpointKey(ep)] = ep
+			if !p.compareProperties(ep, byKey[p.endpointKey(ep)]) {
+				diverged = true
+			}
+		}
+
+		for _, ep := range merged {
+			existing, ok := own[p.endpointKey(ep)]
+			switch {
+			case !ok:
+				p.missing[name] = append(p.missing[name], ep)
+			case hasTarget(ep.RecordType) && len(targetsDifference(ep.Targets, existing.Targets)) > 0:
+				p.missing[name] = append(p.missing[name], withTargets(ep, targetsDifference(ep.Targets, existing.Targets)))
+			}
+		}
+		diverged = diverged || len(p.missing[name]) > 0
+
+		if diverged {
+			log.Warnf("Records on %s have diverged from the other routers, missing %d records", name, len(p.missing[name]))
+			divergedGauge.WithLabelValues(name).Set(1)
+		} else {
+			if p.diverged[name] {
+				log.Infof("Records on %s are in sync with the other routers again", name)
+			}
+			divergedGauge.WithLabelValues(name).Set(0)
+		}
+		p.diverged[name] = diverged
+	}
+
+	return merged
+}
+
+// DivergedRouters returns the names of the routers whose records diverged from the others during the last Records call.
+func (p *MikrotikProvider) DivergedRouters() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var names []string
+	for _, client := range p.clients {
+		if p.diverged[client.RouterName()] {
+			names = append(names, client.RouterName())
+		}
+	}
+	return names
+}
+
+// routerDiverged checks if the records of a router diverged from the others during the last Records call
+func (p *MikrotikProvider) routerDiverged(client *MikrotikApiClient) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.diverged[client.RouterName()]
+}
+
+// repairEnabled checks if the records a diverged router misses are created on it
+func (p *MikrotikProvider) repairEnabled() bool {
+	return p.config != nil && p.config.RepairDivergedRouters && p.config.OwnerID != ""
+}
+
+// repairEndpoints returns the records a diverged router missed during the last Records call, except for the targets
+// that are deleted, updated or created by the changes anyway, so that they are created on it. Nothing is repaired
+// unless repairing diverged routers is enabled.
+func (p *MikrotikProvider) repairEndpoints(client *MikrotikApiClient, deletes []*endpoint.Endpoint, updates []endpointUpdate, creates []*endpoint.Endpoint) []*endpoint.Endpoint {
+	if !p.repairEnabled() {
+		return []*endpoint.Endpoint{}
+	}
+
+	p.mu.Lock()
+	missing := p.missing[client.RouterName()]
+	p.mu.Unlock()
+
+	changed := map[string]endpoint.Targets{}
+	for _, ep := range slices.Concat(deletes, creates) {
+		changed[p.endpointKey(ep)] = append(changed[p.endpointKey(ep)], ep.Targets...)
+	}
+	for _, update := range updates {
+		changed[p.endpointKey(update.old)] = append(changed[p.endpointKey(update.old)], update.old.Targets...)
+		changed[p.endpointKey(update.new)] = append(changed[p.endpointKey(update.new)], update.new.Targets...)
+	}
+
+	repairs := []*endpoint.Endpoint{}
+	for _, ep := range missing {
+		targets, ok := changed[p.endpointKey(ep)]
+		if !ok {
+			repairs = append(repairs, ep)
+			continue
+		}
+		if hasTarget(ep.RecordType) && len(targetsDifference(ep.Targets, targets)) > 0 {
+			repairs = append(repairs, withTargets(ep, targetsDifference(ep.Targets, targets)))
+		}
+	}
+	if len(repairs) > 0 {
+		log.Infof("Creating %d records missing on diverged router %s", len(repairs), client.RouterName())
+	}
+	return repairs
+}
+
+// ApplyChanges applies a given set of changes on all routers.
+// A failure on one router does not stop the changes from being applied on the others, and endpoints that cannot be
+// stored on a router do not stop the other changes from being applied: they are skipped and reported in the error.
+// Errors are returned as a *ProviderError.
 func (p *MikrotikProvider) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
-	changes = p.changes(changes)
+	changes, conflicts := p.changes(changes)
+	changes, invalid := p.validChanges(changes)
+	deletes, updates, creates := p.targetChanges(changes)
+	early, creates := p.earlyCreates(deletes, creates)
 
-	for _, endpoint := range append(changes.UpdateOld, changes.Delete...) {
-		if err := p.client.DeleteDNSRecord(endpoint); err != nil {
-			return err
+	repairs := make([][]*endpoint.Endpoint, len(p.clients))
+	for i, client := range p.clients {
+		routerRepairs, err := p.routerEndpoints(p.repairEndpoints(client, deletes, updates, slices.Concat(early, creates)))
+		if err != nil {
+			return newProviderError(err)
+		}
+		repairs[i] = routerRepairs
+	}
+
+	early, err := p.routerEndpoints(early)
+	if err != nil {
+		return newProviderError(err)
+	}
+	deletes, err = p.routerEndpoints(deletes)
+	if err != nil {
+		return newProviderError(err)
+	}
+	updates, err = p.routerUpdates(updates)
+	if err != nil {
+		return newProviderError(err)
+	}
+	creates, err = p.routerEndpoints(creates)
+	if err != nil {
+		return newProviderError(err)
+	}
+
+	errs := make([]error, len(p.clients))
+	var wg sync.WaitGroup
+	for i, client := range p.clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			creates, _ := dependencyOrder(slices.Concat(creates, repairs[i]))
+			errs[i] = p.applyRouterChanges(ctx, client, early, deletes, updates, creates)
+			p.recordApply(client, errs[i])
+		}()
+	}
+	wg.Wait()
+
+	if p.dryRunEnabled() {
+		p.reportDryRun(errs)
+	}
+
+	return newProviderError(errors.Join(slices.Concat(conflicts, invalid, []error{routerErrors(p.clients, errs)})...))
+}
+
+// validChanges removes the endpoints that cannot be stored on a router from the changes, and returns an *EndpointError
+// for each of them. Updates of a name are removed as a whole when any of its endpoints is invalid, so that the old
+// version of an endpoint is not deleted when its new version cannot be created.
+func (p *MikrotikProvider) validChanges(changes *plan.Changes) (*plan.Changes, []error) {
+	var errs []error
+	invalid := map[*endpoint.Endpoint]bool{}
+	for _, ep := range slices.Concat(changes.Create, changes.Delete, changes.UpdateOld, changes.UpdateNew) {
+		if err := p.validateEndpoint(ep); err != nil {
+			log.Warnf("Skipping endpoint that cannot be stored on a router: %v", err)
+			errs = append(errs, err)
+			invalid[ep] = true
 		}
 	}
+	if len(errs) == 0 {
+		return changes, nil
+	}
+
+	invalidUpdates := map[string]bool{}
+	for _, ep := range slices.Concat(changes.UpdateOld, changes.UpdateNew) {
+		if invalid[ep] {
+			invalidUpdates[strings.ToLower(ep.DNSName)] = true
+		}
+	}
+
+	keep := func(endpoints []*endpoint.Endpoint, update bool) []*endpoint.Endpoint {
+		result := []*endpoint.Endpoint{}
+		for _, ep := range endpoints {
+			if !invalid[ep] && !(update && invalidUpdates[strings.ToLower(ep.DNSName)]) {
+				result = append(result, ep)
+			}
+		}
+		return result
+	}
+
+	return &plan.Changes{
+		Create:    keep(changes.Create, false),
+		Delete:    keep(changes.Delete, false),
+		UpdateOld: keep(changes.UpdateOld, true),
+		UpdateNew: keep(changes.UpdateNew, true),
+	}, errs
+}
+
+// validateEndpoint checks that an endpoint can be stored on a router, returning an *EndpointError if it cannot
+func (p *MikrotikProvider) validateEndpoint(ep *endpoint.Endpoint) error {
+	mapped, err := p.routerEndpoints([]*endpoint.Endpoint{ep})
+	if err == nil {
+		_, err = NewDNSRecords(mapped[0])
+	}
+	if err != nil {
+		return &EndpointError{Endpoint: ep, Err: fmt.Errorf("%w: %w", ErrInvalidEndpoint, err)}
+	}
+	return nil
+}
+
+// reportDryRun logs the operations that were recorded on each router instead of being applied, and keeps them as the
+// last dry-run plan
+func (p *MikrotikProvider) reportDryRun(errs []error) {
+	dryRun := &DryRunPlan{Time: time.Now()}
+	for i, client := range p.clients {
+		routerPlan := RouterDryRun{Router: client.RouterName(), Operations: client.dryRun.take()}
+		if errs[i] != nil {
+			routerPlan.Error = errs[i].Error()
+		}
+		dryRun.Routers = append(dryRun.Routers, routerPlan)
+
+		log.Infof("dry run: %d changes would have been applied on %s", len(routerPlan.Operations), client.RouterName())
+		for _, operation := range routerPlan.Operations {
+			log.Infof("dry run on %s: %s", client.RouterName(), operation)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastDryRun = dryRun
+}
+
+// DryRunPlan returns the operations the last ApplyChanges call would have made, as a *DryRunPlan.
+// It is nil when dry-run mode is disabled.
+func (p *MikrotikProvider) DryRunPlan() any {
+	if !p.dryRunEnabled() {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.lastDryRun == nil {
+		return &DryRunPlan{Routers: []RouterDryRun{}}
+	}
+	return p.lastDryRun
+}
+
+// applyRouterChanges creates the early endpoints, then deletes, updates and creates the given endpoints on a single
+// router, as a transaction. CNAME records updated to point to a created endpoint are updated after it is created.
+// If any step fails, the changes made so far are rolled back and a *TransactionError is returned.
+func (p *MikrotikProvider) applyRouterChanges(
+	ctx context.Context,
+	client *MikrotikApiClient,
+	early, deletes []*endpoint.Endpoint,
+	updates []endpointUpdate,
+	creates []*endpoint.Endpoint,
+) error {
+	tx := &transaction{client: client}
 
-	for _, endpoint := range append(changes.Create, changes.UpdateNew...) {
-		if _, err := p.client.CreateDNSRecord(endpoint); err != nil {
+	for _, endpoint := range early {
+		created, err := client.CreateDNSRecord(ctx, endpoint)
+		tx.created = append(tx.created, created...)
+		if err != nil {
+			return tx.rollback(ctx, &EndpointError{Endpoint: endpoint, Err: err})
+		}
+	}
+
+	// A diverged router may miss some of the records to delete or update: they are deleted one target at a time, so
+	// that the missing ones can be skipped, and updates of missing records create them instead
+	diverged := p.routerDiverged(client)
+	if diverged {
+		deletes = singleTargets(deletes)
+	}
+
+	for _, endpoint := range deletes {
+		deleted, err := client.DeleteDNSRecord(ctx, endpoint)
+		tx.deleted = append(tx.deleted, deleted...)
+		if diverged && errors.Is(err, ErrNotFound) {
+			log.Infof("Record %s %s to delete is already missing on diverged router %s", endpoint.DNSName, endpoint.RecordType, client.RouterName())
+			continue
+		}
+		if err != nil {
+			return tx.rollback(ctx, &EndpointError{Endpoint: endpoint, Err: err})
+		}
+	}
+
+	apply := func(update endpointUpdate) error {
+		before, after, err := client.UpdateDNSRecord(ctx, update.old, update.new)
+		if diverged && errors.Is(err, ErrNotFound) {
+			log.Infof("Record %s %s to update is missing on diverged router %s, creating it", update.new.DNSName, update.new.RecordType, client.RouterName())
+			created, err := client.CreateDNSRecord(ctx, update.new)
+			tx.created = append(tx.created, created...)
+			return err
+		}
+		if err != nil {
 			return err
 		}
+		tx.updated = append(tx.updated, recordUpdate{before: before, after: after})
+		return nil
+	}
+
+	// CNAME records updated to point to a created name are only updated once it is created
+	updates, late := lateUpdates(updates, creates)
+
+	for _, update := range updates {
+		if err := apply(update); err != nil {
+			return tx.rollback(ctx, &EndpointError{Endpoint: update.new, Err: err})
+		}
 	}
 
+	for _, endpoint := range creates {
+		created, err := client.CreateDNSRecord(ctx, endpoint)
+		tx.created = append(tx.created, created...)
+		if err != nil {
+			return tx.rollback(ctx, &EndpointError{Endpoint: endpoint, Err: err})
+		}
+	}
+
+	for _, update := range late {
+		if err := apply(update); err != nil {
+			return tx.rollback(ctx, &EndpointError{Endpoint: update.new, Err: err})
+		}
+	}
+
+	appliedChangesHistogram.WithLabelValues(client.RouterName(), "delete").Observe(float64(len(tx.deleted)))
+	appliedChangesHistogram.WithLabelValues(client.RouterName(), "update").Observe(float64(len(tx.updated)))
+	appliedChangesHistogram.WithLabelValues(client.RouterName(), "create").Observe(float64(len(tx.created)))
+
 	return nil
 }
 
+// recordRead updates the health and sync metrics of a router after its records were read, failing with the given error.
+// The sync is only successful if the last changes were applied on the router as well.
+func (p *MikrotikProvider) recordRead(client *MikrotikApiClient, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	health := p.routerHealth(client)
+	health.recordsErr = err
+	if err == nil && health.applyErr == nil {
+		lastSyncGauge.WithLabelValues(client.RouterName()).SetToCurrentTime()
+	}
+}
+
+// recordApply updates the health and sync metrics of a router after changes were applied on it, failing with the given error
+func (p *MikrotikProvider) recordApply(client *MikrotikApiClient, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.routerHealth(client).applyErr = err
+	if err != nil {
+		applyChangesCounter.WithLabelValues(client.RouterName(), "failure").Inc()
+		return
+	}
+
+	// The records missed by a diverged router were created along with the changes
+	if !p.dryRunEnabled() {
+		delete(p.missing, client.RouterName())
+	}
+	applyChangesCounter.WithLabelValues(client.RouterName(), "success").Inc()
+	lastSyncGauge.WithLabelValues(client.RouterName()).SetToCurrentTime()
+}
+
+// AdjustEndpoints modifies the endpoints before they are planned, so they have the same shape as the ones returned by Records.
+// Names are lowercased without their trailing dot, targets are canonicalized and the defaults are filled in. Targets
+// that cannot be stored on a router are dropped, along with the endpoints left without any, or of unsupported types.
+// Regexp records are renamed to the synthetic name of their pattern, when regexp records are exposed under one.
+func (p *MikrotikProvider) AdjustEndpoints(endpoints []*endpoint.Endpoint) ([]*endpoint.Endpoint, error) {
+	adjusted := make([]*endpoint.Endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		ep.DNSName = normalizeDomain(ep.DNSName)
+		if pattern := p.getProviderSpecificOrDefault(ep, "regexp", ""); p.regexpNamesEnabled() && pattern != "" {
+			name := regexpRecordName(pattern, p.config.RegexpNameSuffix)
+			if ep.DNSName != name {
+				log.Debugf("Renaming regexp record '%s' from %s to its synthetic name: %s", pattern, ep.DNSName, name)
+				ep.DNSName = name
+			}
+		}
+
+		if !hasTarget(ep.RecordType) {
+			log.Debugf("Removing empty targets from endpoint: %v", ep)
+			ep.Targets = nonEmptyTargets(ep.Targets)
+		} else {
+			targets := endpoint.Targets{}
+			for _, target := range ep.Targets {
+				normalized, err := normalizeTarget(ep.RecordType, target)
+				if err != nil {
+					log.Warnf("Dropping target %q of endpoint %s %s: %v", target, ep.DNSName, ep.RecordType, err)
+					continue
+				}
+				if !containsTarget(targets, normalized) {
+					targets = append(targets, normalized)
+				}
+			}
+			if len(targets) == 0 {
+				log.Warnf("Dropping endpoint %s %s without any valid target", ep.DNSName, ep.RecordType)
+				continue
+			}
+			ep.Targets = targets
+		}
+
+		p.applyDefaults(ep)
+
+		if _, err := NewDNSRecords(ep); err != nil {
+			log.Warnf("Dropping endpoint %s %s, which cannot be stored on a router: %v", ep.DNSName, ep.RecordType, err)
+			continue
+		}
+		adjusted = append(adjusted, ep)
+	}
+	return adjusted, nil
+}
+
 // GetDomainFilter returns the domain filter for the provider.
 func (p *MikrotikProvider) GetDomainFilter() endpoint.DomainFilterInterface {
 	return p.domainFilter
@@ -116,6 +717,74 @@ func (p *MikrotikProvider) getProviderSpecificOrDefault(ep *endpoint.Endpoint, p
 	return defaultValue
 }
 
+// routerErrors joins the errors that occurred on each router, so that a failure on one router does not hide the others.
+func routerErrors(clients []*MikrotikApiClient, errs []error) error {
+	var joined []error
+	for i, err := range errs {
+		if err != nil {
+			joined = append(joined, fmt.Errorf("%s: %w", clients[i].RouterName(), err))
+		}
+	}
+	return errors.Join(joined...)
+}
+
+// dryRunEnabled checks if changes are only recorded instead of being applied on the routers.
+func (p *MikrotikProvider) dryRunEnabled() bool {
+	return p.config != nil && p.config.DryRun
+}
+
+// regexpNamesEnabled checks if regexp records are exposed to ExternalDNS under synthetic names.
+func (p *MikrotikProvider) regexpNamesEnabled() bool {
+	return p.config != nil && p.config.RegexpNameSuffix != ""
+}
+
+// routerEndpoints maps the endpoints to the shape in which they are stored on the router.
+// Regexp records exposed under a synthetic name are turned back into nameless regexp records, and labels are appended
+// to the comment when they are stored in comments.
+func (p *MikrotikProvider) routerEndpoints(endpoints []*endpoint.Endpoint) ([]*endpoint.Endpoint, error) {
+	if !p.regexpNamesEnabled() && !p.commentLabelsEnabled() {
+		return endpoints, nil
+	}
+
+	result := make([]*endpoint.Endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if p.commentLabelsEnabled() {
+			ep = p.withCommentLabels(ep)
+		}
+
+		pattern := p.getProviderSpecificOrDefault(ep, "regexp", "")
+		if !p.regexpNamesEnabled() || pattern == "" {
+			result = append(result, ep)
+			continue
+		}
+
+		name := regexpRecordName(pattern, p.config.RegexpNameSuffix)
+		if !strings.EqualFold(ep.DNSName, name) {
+			return nil, fmt.Errorf("regexp record '%s' must be named %s, got: %s", pattern, name, ep.DNSName)
+		}
+
+		log.Debugf("Mapping %s back to regexp record: %s", ep.DNSName, pattern)
+		copied := *ep
+		copied.DNSName = ""
+		result = append(result, &copied)
+	}
+
+	return result, nil
+}
+
+// routerUpdates maps both sides of the updates to the shape in which they are stored on the router, like routerEndpoints.
+func (p *MikrotikProvider) routerUpdates(updates []endpointUpdate) ([]endpointUpdate, error) {
+	result := make([]endpointUpdate, 0, len(updates))
+	for _, update := range updates {
+		mapped, err := p.routerEndpoints([]*endpoint.Endpoint{update.old, update.new})
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, endpointUpdate{old: mapped[0], new: mapped[1]})
+	}
+	return result, nil
+}
+
 // compareEndpoints compares two endpoints to determine if they are identical, keeping in mind empty/default states.
 func (p *MikrotikProvider) compareEndpoints(a *endpoint.Endpoint, b *endpoint.Endpoint) bool {
 	log.Debugf("Comparing endpoint a: %v", a)
@@ -126,13 +795,18 @@ func (p *MikrotikProvider) compareEndpoints(a *endpoint.Endpoint, b *endpoint.En
 		return false
 	}
 
-	if a.Targets[0] != b.Targets[0] {
-		log.Debugf("Targets[0] mismatch: %v != %v", a.Targets[0], b.Targets[0])
+	if !sameTargets(a.Targets, b.Targets) {
+		log.Debugf("Targets mismatch: %v != %v", a.Targets, b.Targets)
 		return false
 	}
 
-	aRelevantTTL := a.RecordTTL != 0 && a.RecordTTL != endpoint.TTL(p.client.DefaultTTL)
-	bRelevantTTL := b.RecordTTL != 0 && b.RecordTTL != endpoint.TTL(p.client.DefaultTTL)
+	return p.compareProperties(a, b)
+}
+
+// compareProperties compares the TTL and provider-specific properties of two endpoints, keeping in mind empty/default states.
+func (p *MikrotikProvider) compareProperties(a *endpoint.Endpoint, b *endpoint.Endpoint) bool {
+	aRelevantTTL := a.RecordTTL != 0 && a.RecordTTL != p.defaultTTL(a)
+	bRelevantTTL := b.RecordTTL != 0 && b.RecordTTL != p.defaultTTL(b)
 	if a.RecordTTL != b.RecordTTL && (aRelevantTTL || bRelevantTTL) {
 		log.Debugf("RecordTTL mismatch: %v != %v", a.RecordTTL, b.RecordTTL)
 		return false
@@ -140,34 +814,39 @@ func (p *MikrotikProvider) compareEndpoints(a *endpoint.Endpoint, b *endpoint.En
 
 	aComment := p.getProviderSpecificOrDefault(a, "comment", "")
 	bComment := p.getProviderSpecificOrDefault(b, "comment", "")
-	aRelevantComment := aComment != "" && aComment != p.client.DefaultComment
-	bRelevantComment := bComment != "" && bComment != p.client.DefaultComment
+	aRelevantComment := !p.isDefaultComment(aComment, a, b)
+	bRelevantComment := !p.isDefaultComment(bComment, a, b)
 	if aComment != bComment && (aRelevantComment || bRelevantComment) {
 		log.Debugf("Comment mismatch: %v != %v", aComment, bComment)
 		return false
 	}
 
-	aMatchSubdomain := p.getProviderSpecificOrDefault(a, "match-subdomain", "false")
-	bMatchSubdomain := p.getProviderSpecificOrDefault(b, "match-subdomain", "false")
+	aMatchSubdomain := p.getProviderSpecificOrDefault(a, "match-subdomain", p.defaultProperty(a, "match-subdomain", "false"))
+	bMatchSubdomain := p.getProviderSpecificOrDefault(b, "match-subdomain", p.defaultProperty(b, "match-subdomain", "false"))
 	if aMatchSubdomain != bMatchSubdomain {
 		log.Debugf("MatchSubdomain mismatch: %v != %v", aMatchSubdomain, bMatchSubdomain)
 		return false
 	}
 
-	aDisabled := p.getProviderSpecificOrDefault(a, "disabled", "false")
-	bDisabled := p.getProviderSpecificOrDefault(b, "disabled", "false")
+	aDisabled := p.getProviderSpecificOrDefault(a, "disabled", p.defaultProperty(a, "disabled", "false"))
+	bDisabled := p.getProviderSpecificOrDefault(b, "disabled", p.defaultProperty(b, "disabled", "false"))
 	if aDisabled != bDisabled {
 		log.Debugf("Disabled mismatch: %v != %v", aDisabled, bDisabled)
 		return false
 	}
 
-	aAddressList := p.getProviderSpecificOrDefault(a, "address-list", "")
-	bAddressList := p.getProviderSpecificOrDefault(b, "address-list", "")
+	aAddressList := p.getProviderSpecificOrDefault(a, "address-list", p.defaultProperty(a, "address-list", ""))
+	bAddressList := p.getProviderSpecificOrDefault(b, "address-list", p.defaultProperty(b, "address-list", ""))
 	if aAddressList != bAddressList {
 		log.Debugf("AddressList mismatch: %v != %v", aAddressList, bAddressList)
 		return false
 	}
 
+	if p.commentLabelsEnabled() && !sameLabels(a, b) {
+		log.Debugf("Labels mismatch: %v != %v", a.Labels, b.Labels)
+		return false
+	}
+
 	aRegexp := p.getProviderSpecificOrDefault(a, "regexp", "")
 	bRegexp := p.getProviderSpecificOrDefault(b, "regexp", "")
 	if aRegexp != bRegexp {
@@ -179,6 +858,19 @@ func (p *MikrotikProvider) compareEndpoints(a *endpoint.Endpoint, b *endpoint.En
 	return true
 }
 
+// endpointKey returns the key identifying the set of static entries an endpoint maps to. Entries of the same name and
+// type only match the same names if their match-subdomain flags are the same, so the flag is part of the key.
+func (p *MikrotikProvider) endpointKey(ep *endpoint.Endpoint) string {
+	matchSubdomain := p.getProviderSpecificOrDefault(ep, "match-subdomain", p.defaultProperty(ep, "match-subdomain", "false"))
+	return fmt.Sprintf("%s|%s", p.updateKey(ep), matchSubdomain)
+}
+
+// updateKey returns the key pairing the UpdateOld and UpdateNew endpoints of a plan. Unlike endpointKey it leaves out
+// the match-subdomain flag, so that a change of the flag updates the entries in place.
+func (p *MikrotikProvider) updateKey(ep *endpoint.Endpoint) string {
+	return fmt.Sprintf("%s|%s|%s", strings.ToLower(ep.DNSName), ep.RecordType, p.getProviderSpecificOrDefault(ep, "regexp", ""))
+}
+
 func (p *MikrotikProvider) listContains(haystack []*endpoint.Endpoint, needle *endpoint.Endpoint) bool {
 	for _, v := range haystack {
 		if p.compareEndpoints(needle, v) {
@@ -189,8 +881,9 @@ func (p *MikrotikProvider) listContains(haystack []*endpoint.Endpoint, needle *e
 }
 
 // changes processes and filters the changes plan for updates.
-// It adjusts TTL for created endpoints and removes duplicate updates from the plan.
-func (p *MikrotikProvider) changes(changes *plan.Changes) *plan.Changes {
+// It fills in the defaults of created and updated endpoints, removes duplicate updates from the plan and plans the
+// resulting batch: the changes of names whose endpoints contradict each other are removed and returned as errors.
+func (p *MikrotikProvider) changes(changes *plan.Changes) (*plan.Changes, []error) {
 	log.Debug("Starting to process changes plan.")
 
 	// Initialize new plan -> we don't really need to worry about Delete changes.
@@ -206,19 +899,9 @@ func (p *MikrotikProvider) changes(changes *plan.Changes) *plan.Changes {
 
 	// Process Create changes
 	for _, create := range changes.Create {
-		// Enforce Default TTL
-		if !create.RecordTTL.IsConfigured() {
-			log.Debugf("Setting default TTL for created endpoint: %v", create)
-			create.RecordTTL = endpoint.TTL(p.client.DefaultTTL)
-		}
-
-		// Enforce Default Comment
-		if p.client.DefaultComment != "" {
-			if p.getProviderSpecificOrDefault(create, "comment", "") == "" {
-				log.Debugf("Setting default comment for created endpoint: %v", create)
-				create.SetProviderSpecificProperty("comment", p.client.DefaultComment)
-			}
-		}
+		// Enforce Default TTL, Comment and properties
+		log.Debugf("Setting defaults for created endpoint: %v", create)
+		p.applyDefaults(create)
 
 		newChanges.Create = append(newChanges.Create, create)
 	}
@@ -247,25 +930,161 @@ func (p *MikrotikProvider) changes(changes *plan.Changes) *plan.Changes {
 		if !p.listContains(duplicates, new) {
 			log.Debugf("Adding non-duplicate UpdateNew endpoint: %v", new)
 
-			// Enforce Default TTL
-			if !new.RecordTTL.IsConfigured() {
-				log.Debugf("Setting default TTL for UpdateNew endpoint: %v", new)
-				new.RecordTTL = endpoint.TTL(p.client.DefaultTTL)
+			// Enforce Default TTL, Comment and properties
+			log.Debugf("Setting defaults for UpdateNew endpoint: %v", new)
+			p.applyDefaults(new)
+
+			newChanges.UpdateNew = append(newChanges.UpdateNew, new)
+		}
+	}
+
+	newChanges, errs := p.planChanges(newChanges)
+
+	log.Debugf("Processed changes - Create: %d, Delete: %d, UpdateOld: %d, UpdateNew: %d", len(newChanges.Create), len(newChanges.Delete), len(newChanges.UpdateOld), len(newChanges.UpdateNew))
+	log.Debug("Finished processing changes plan.")
+	return newChanges, errs
+}
+
+// endpointUpdate is an in-place change of a single static entry, from a target of an UpdateOld endpoint to a target of
+// the matching UpdateNew endpoint. Both endpoints point to a single target, or none for types without target.
+type endpointUpdate struct {
+	old *endpoint.Endpoint
+	new *endpoint.Endpoint
+}
+
+// targetChanges breaks the changes plan down into the endpoints whose targets have to be deleted from, updated in place
+// on, and created on the router. Updates are paired by name and type: targets kept by an update are only touched when
+// the properties changed, and removed targets are updated in place to point to added ones. Only the targets left over
+// on either side are deleted or created. A change of the record type cannot be paired, so it deletes and creates.
+func (p *MikrotikProvider) targetChanges(changes *plan.Changes) ([]*endpoint.Endpoint, []endpointUpdate, []*endpoint.Endpoint) {
+	deletes := append([]*endpoint.Endpoint{}, changes.Delete...)
+	updates := []endpointUpdate{}
+	creates := append([]*endpoint.Endpoint{}, changes.Create...)
+
+	paired := map[*endpoint.Endpoint]bool{}
+	for _, new := range changes.UpdateNew {
+		var old *endpoint.Endpoint
+		for _, candidate := range changes.UpdateOld {
+			if !paired[candidate] && p.updateKey(candidate) == p.updateKey(new) {
+				old = candidate
+				break
 			}
+		}
+
+		if old == nil {
+			log.Debugf("No matching UpdateOld endpoint found, creating all targets of: %v", new)
+			creates = append(creates, new)
+			continue
+		}
+		paired[old] = true
 
-			// Enforce Default Comment
-			if p.client.DefaultComment != "" {
-				if p.getProviderSpecificOrDefault(new, "comment", "") == "" {
-					log.Debugf("Setting default comment for UpdateNew endpoint: %v", new)
-					new.SetProviderSpecificProperty("comment", p.client.DefaultComment)
+		propertiesChanged := !p.compareProperties(old, new)
+		if !hasTarget(new.RecordType) {
+			if propertiesChanged {
+				log.Debugf("Properties changed, updating endpoint: %v", old)
+				updates = append(updates, endpointUpdate{old: old, new: new})
+			}
+			continue
+		}
+
+		if propertiesChanged {
+			log.Debugf("Properties changed, updating all kept targets of: %v", old)
+			for _, oldTarget := range nonEmptyTargets(old.Targets) {
+				for _, newTarget := range new.Targets {
+					if sameTarget(oldTarget, newTarget) {
+						updates = append(updates, endpointUpdate{
+							old: withTargets(old, endpoint.Targets{oldTarget}),
+							new: withTargets(new, endpoint.Targets{newTarget}),
+						})
+						break
+					}
 				}
 			}
+		}
 
-			newChanges.UpdateNew = append(newChanges.UpdateNew, new)
+		removed := targetsDifference(old.Targets, new.Targets)
+		added := targetsDifference(new.Targets, old.Targets)
+		for len(removed) > 0 && len(added) > 0 {
+			log.Debugf("Changing target %s of endpoint %v to: %s", removed[0], old, added[0])
+			updates = append(updates, endpointUpdate{
+				old: withTargets(old, endpoint.Targets{removed[0]}),
+				new: withTargets(new, endpoint.Targets{added[0]}),
+			})
+			removed, added = removed[1:], added[1:]
+		}
+		if len(removed) > 0 {
+			log.Debugf("Removing targets %v from endpoint: %v", removed, old)
+			deletes = append(deletes, withTargets(old, removed))
+		}
+		if len(added) > 0 {
+			log.Debugf("Adding targets %v to endpoint: %v", added, new)
+			creates = append(creates, withTargets(new, added))
 		}
 	}
 
-	log.Debugf("Processed changes - Create: %d, Delete: %d, UpdateOld: %d, UpdateNew: %d", len(newChanges.Create), len(newChanges.Delete), len(newChanges.UpdateOld), len(newChanges.UpdateNew))
-	log.Debug("Finished processing changes plan.")
-	return newChanges
+	for _, old := range changes.UpdateOld {
+		if !paired[old] {
+			log.Debugf("No matching UpdateNew endpoint found, deleting all targets of: %v", old)
+			deletes = append(deletes, old)
+		}
+	}
+
+	// The targets of CNAME records created from updates may be created from the Create endpoints, or the other way around.
+	// Loops were rejected when planning the batch.
+	creates, cyclic := dependencyOrder(creates)
+	for _, ep := range cyclic {
+		log.Errorf("CNAME record of %s points to itself through other created CNAME records", ep.DNSName)
+	}
+
+	return deletes, updates, creates
+}
+
+// singleTargets splits the endpoints into copies pointing to a single one of their targets each. Endpoints without
+// targets are kept as they are.
+func singleTargets(endpoints []*endpoint.Endpoint) []*endpoint.Endpoint {
+	result := make([]*endpoint.Endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		targets := nonEmptyTargets(ep.Targets)
+		if len(targets) <= 1 {
+			result = append(result, ep)
+			continue
+		}
+		for _, target := range targets {
+			result = append(result, withTargets(ep, endpoint.Targets{target}))
+		}
+	}
+	return result
+}
+
+// withTargets returns a copy of the endpoint pointing only to the given targets.
+func withTargets(ep *endpoint.Endpoint, targets endpoint.Targets) *endpoint.Endpoint {
+	copied := *ep
+	copied.Targets = targets
+	return &copied
+}
+
+// targetsDifference returns the non-empty targets in a that are not present in b.
+func targetsDifference(a, b endpoint.Targets) endpoint.Targets {
+	difference := endpoint.Targets{}
+	for _, target := range a {
+		if target != "" && !containsTarget(b, target) {
+			difference = append(difference, target)
+		}
+	}
+	return difference
+}
+
+// containsTarget checks if the list of targets contains the given target.
+func containsTarget(targets endpoint.Targets, target string) bool {
+	for _, t := range targets {
+		if sameTarget(t, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameTargets checks if two lists of targets contain the same targets, regardless of their order and empty targets.
+func sameTargets(a, b endpoint.Targets) bool {
+	return len(targetsDifference(a, b)) == 0 && len(targetsDifference(b, a)) == 0
 }
@@ -1,8 +1,21 @@
 package mikrotik
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"sigs.k8s.io/external-dns/endpoint"
 	"sigs.k8s.io/external-dns/plan"
 )
@@ -30,13 +43,9 @@ func NewEndpoint(dnsName, target string, ttl int64, providerSpecificProps []map[
 
 func TestGetProviderSpecificOrDefault(t *testing.T) {
 	mikrotikProvider := &MikrotikProvider{
-		client: &MikrotikApiClient{
-			&MikrotikDefaults{
-				DefaultTTL:     defaultTTL,
-				DefaultComment: defaultComment,
-			},
-			nil,
-			nil,
+		defaults: &MikrotikDefaults{
+			DefaultTTL:     defaultTTL,
+			DefaultComment: defaultComment,
 		},
 	}
 	tests := []struct {
@@ -88,13 +97,9 @@ func TestGetProviderSpecificOrDefault(t *testing.T) {
 
 func TestCompareEndpoints(t *testing.T) {
 	mikrotikProvider := &MikrotikProvider{
-		client: &MikrotikApiClient{
-			&MikrotikDefaults{
-				DefaultTTL:     int64(defaultTTL),
-				DefaultComment: defaultComment,
-			},
-			nil,
-			nil,
+		defaults: &MikrotikDefaults{
+			DefaultTTL:     int64(defaultTTL),
+			DefaultComment: defaultComment,
 		},
 	}
 	tests := []struct {
@@ -120,6 +125,14 @@ func TestCompareEndpoints(t *testing.T) {
 			expectedMatch: true,
 		},
 
+		{
+			name:          "Matching multiple targets in different order",
+			provider:      mikrotikProvider,
+			endpointA:     &endpoint.Endpoint{DNSName: "example.com", Targets: endpoint.NewTargets("192.0.2.1", "192.0.2.2"), RecordTTL: 3600},
+			endpointB:     &endpoint.Endpoint{DNSName: "example.com", Targets: endpoint.NewTargets("192.0.2.2", "192.0.2.1"), RecordTTL: 3600},
+			expectedMatch: true,
+		},
+
 		// EDGE CASES
 		{
 			name:          "Match-Subdomain: 'false' and unspecified should match",
@@ -284,6 +297,13 @@ func TestCompareEndpoints(t *testing.T) {
 			endpointB:     NewEndpoint("example.com", "192.0.2.1", 3600, []map[string]string{{"address-list": "2.3.4.5"}}),
 			expectedMatch: false,
 		},
+		{
+			name:          "Mismatch in number of targets",
+			provider:      mikrotikProvider,
+			endpointA:     &endpoint.Endpoint{DNSName: "example.com", Targets: endpoint.NewTargets("192.0.2.1", "192.0.2.2"), RecordTTL: 3600},
+			endpointB:     &endpoint.Endpoint{DNSName: "example.com", Targets: endpoint.NewTargets("192.0.2.1"), RecordTTL: 3600},
+			expectedMatch: false,
+		},
 		{
 			name:          "Mismatch in regexp",
 			provider:      mikrotikProvider,
@@ -306,12 +326,8 @@ func TestCompareEndpoints(t *testing.T) {
 func TestListContains(t *testing.T) {
 	defaultTTL := 1800
 	mikrotikProvider := &MikrotikProvider{
-		client: &MikrotikApiClient{
-			&MikrotikDefaults{
-				DefaultTTL: int64(defaultTTL),
-			},
-			nil,
-			nil,
+		defaults: &MikrotikDefaults{
+			DefaultTTL: int64(defaultTTL),
 		},
 	}
 	tests := []struct {
@@ -364,13 +380,9 @@ func TestListContains(t *testing.T) {
 
 func TestChanges(t *testing.T) {
 	mikrotikProvider := &MikrotikProvider{
-		client: &MikrotikApiClient{
-			&MikrotikDefaults{
-				DefaultTTL:     int64(defaultTTL),
-				DefaultComment: defaultComment,
-			},
-			nil,
-			nil,
+		defaults: &MikrotikDefaults{
+			DefaultTTL:     int64(defaultTTL),
+			DefaultComment: defaultComment,
 		},
 	}
 
@@ -609,7 +621,7 @@ func TestChanges(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			outputChanges := tt.provider.changes(tt.inputChanges)
+			outputChanges, _ := tt.provider.changes(tt.inputChanges)
 
 			if len(outputChanges.UpdateOld) != len(tt.expectedChanges.UpdateOld) {
 				t.Errorf("Expected UpdateOld length %d, got %d", len(tt.expectedChanges.UpdateOld), len(outputChanges.UpdateOld))
@@ -639,3 +651,885 @@ func TestChanges(t *testing.T) {
 		})
 	}
 }
+
+func TestTargetChanges(t *testing.T) {
+	mikrotikProvider := &MikrotikProvider{
+		defaults: &MikrotikDefaults{
+			DefaultTTL:     defaultTTL,
+			DefaultComment: defaultComment,
+		},
+	}
+	tests := []struct {
+		name            string
+		inputChanges    *plan.Changes
+		expectedDeletes []*endpoint.Endpoint
+		expectedUpdates []endpointUpdate
+		expectedCreates []*endpoint.Endpoint
+	}{
+		{
+			name: "Creates and deletes are passed through",
+			inputChanges: &plan.Changes{
+				Create: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("new.com", "A", 3600, "1.1.1.1", "2.2.2.2")},
+				Delete: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("old.com", "A", 3600, "3.3.3.3")},
+			},
+			expectedDeletes: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("old.com", "A", 3600, "3.3.3.3")},
+			expectedCreates: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("new.com", "A", 3600, "1.1.1.1", "2.2.2.2")},
+		},
+		{
+			name: "Removed targets are changed in place to added ones",
+			inputChanges: &plan.Changes{
+				UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("rr.com", "A", 3600, "1.1.1.1", "2.2.2.2")},
+				UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("rr.com", "A", 3600, "2.2.2.2", "3.3.3.3")},
+			},
+			expectedUpdates: []endpointUpdate{
+				{old: endpoint.NewEndpointWithTTL("rr.com", "A", 3600, "1.1.1.1"), new: endpoint.NewEndpointWithTTL("rr.com", "A", 3600, "3.3.3.3")},
+			},
+		},
+		{
+			name: "Left over removed targets are deleted",
+			inputChanges: &plan.Changes{
+				UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("rr.com", "A", 3600, "1.1.1.1", "2.2.2.2", "3.3.3.3")},
+				UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("rr.com", "A", 3600, "4.4.4.4")},
+			},
+			expectedDeletes: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("rr.com", "A", 3600, "2.2.2.2", "3.3.3.3")},
+			expectedUpdates: []endpointUpdate{
+				{old: endpoint.NewEndpointWithTTL("rr.com", "A", 3600, "1.1.1.1"), new: endpoint.NewEndpointWithTTL("rr.com", "A", 3600, "4.4.4.4")},
+			},
+		},
+		{
+			name: "Added target only",
+			inputChanges: &plan.Changes{
+				UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("mx.com", "MX", 3600, "10 mail1.mx.com")},
+				UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("mx.com", "MX", 3600, "10 mail1.mx.com", "20 mail2.mx.com")},
+			},
+			expectedCreates: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("mx.com", "MX", 3600, "20 mail2.mx.com")},
+		},
+		{
+			name: "Changed properties update all kept targets",
+			inputChanges: &plan.Changes{
+				UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("rr.com", "A", 3600, "1.1.1.1", "2.2.2.2")},
+				UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("rr.com", "A", 60, "1.1.1.1", "2.2.2.2", "3.3.3.3")},
+			},
+			expectedUpdates: []endpointUpdate{
+				{old: endpoint.NewEndpointWithTTL("rr.com", "A", 3600, "1.1.1.1"), new: endpoint.NewEndpointWithTTL("rr.com", "A", 60, "1.1.1.1")},
+				{old: endpoint.NewEndpointWithTTL("rr.com", "A", 3600, "2.2.2.2"), new: endpoint.NewEndpointWithTTL("rr.com", "A", 60, "2.2.2.2")},
+			},
+			expectedCreates: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("rr.com", "A", 60, "3.3.3.3")},
+		},
+		{
+			name: "Changed properties of a record without target",
+			inputChanges: &plan.Changes{
+				UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("ads.com", "NXDOMAIN", 3600)},
+				UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("ads.com", "NXDOMAIN", 3600).WithProviderSpecific("match-subdomain", "true")},
+			},
+			expectedUpdates: []endpointUpdate{
+				{old: endpoint.NewEndpointWithTTL("ads.com", "NXDOMAIN", 3600), new: endpoint.NewEndpointWithTTL("ads.com", "NXDOMAIN", 3600).WithProviderSpecific("match-subdomain", "true")},
+			},
+		},
+		{
+			name: "Changed record type deletes and creates",
+			inputChanges: &plan.Changes{
+				UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.com", "A", 3600, "1.1.1.1")},
+				UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.com", "CNAME", 3600, "b.com")},
+			},
+			expectedDeletes: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.com", "A", 3600, "1.1.1.1")},
+			expectedCreates: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.com", "CNAME", 3600, "b.com")},
+		},
+		{
+			name: "Updates are paired by name and type",
+			inputChanges: &plan.Changes{
+				UpdateOld: []*endpoint.Endpoint{
+					endpoint.NewEndpointWithTTL("b.com", "AAAA", 3600, "2001:db8::1"),
+					endpoint.NewEndpointWithTTL("a.com", "A", 3600, "1.1.1.1"),
+				},
+				UpdateNew: []*endpoint.Endpoint{
+					endpoint.NewEndpointWithTTL("a.com", "A", 3600, "1.1.1.1", "1.1.1.2"),
+					endpoint.NewEndpointWithTTL("b.com", "AAAA", 3600, "2001:db8:0:0::1", "2001:db8::2"),
+				},
+			},
+			expectedCreates: []*endpoint.Endpoint{
+				endpoint.NewEndpointWithTTL("a.com", "A", 3600, "1.1.1.2"),
+				endpoint.NewEndpointWithTTL("b.com", "AAAA", 3600, "2001:db8::2"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deletes, updates, creates := mikrotikProvider.targetChanges(tt.inputChanges)
+
+			if len(deletes) != len(tt.expectedDeletes) {
+				t.Fatalf("Expected %d deletes, got %d: %v", len(tt.expectedDeletes), len(deletes), deletes)
+			}
+			if len(updates) != len(tt.expectedUpdates) {
+				t.Fatalf("Expected %d updates, got %d: %v", len(tt.expectedUpdates), len(updates), updates)
+			}
+			if len(creates) != len(tt.expectedCreates) {
+				t.Fatalf("Expected %d creates, got %d: %v", len(tt.expectedCreates), len(creates), creates)
+			}
+
+			for i := range tt.expectedDeletes {
+				if !mikrotikProvider.compareEndpoints(deletes[i], tt.expectedDeletes[i]) {
+					t.Errorf("Expected delete endpoint: %v , got %v", tt.expectedDeletes[i], deletes[i])
+				}
+			}
+			for i := range tt.expectedUpdates {
+				if !mikrotikProvider.compareEndpoints(updates[i].old, tt.expectedUpdates[i].old) ||
+					!mikrotikProvider.compareEndpoints(updates[i].new, tt.expectedUpdates[i].new) {
+					t.Errorf("Expected update: %v -> %v , got %v -> %v", tt.expectedUpdates[i].old, tt.expectedUpdates[i].new, updates[i].old, updates[i].new)
+				}
+			}
+			for i := range tt.expectedCreates {
+				if !mikrotikProvider.compareEndpoints(creates[i], tt.expectedCreates[i]) {
+					t.Errorf("Expected create endpoint: %v , got %v", tt.expectedCreates[i], creates[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRecords(t *testing.T) {
+	records := []DNSRecord{
+		{ID: "*1", Name: "rr.example.com", Address: "192.0.2.1", TTL: "1h"},
+		{ID: "*2", Name: "other.example.com", Type: "CNAME", CName: "rr.example.com", TTL: "1h"},
+		{ID: "*3", Name: "rr.example.com", Type: "A", Address: "192.0.2.2", TTL: "1h"},
+		{ID: "*4", Name: "rr.example.com", Type: "AAAA", Address: "2001:db8::1", TTL: "1h"},
+		{ID: "*5", Name: "filtered.example.org", Type: "A", Address: "192.0.2.3", TTL: "1h"},
+	}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == "/rest/ip/dns/static" {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(records); err != nil {
+				t.Errorf("error json encoding dns records")
+			}
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	client, err := NewMikrotikClient(&MikrotikConnectionConfig{BaseUrls: []string{server.URL}, SkipTLSVerify: true}, &MikrotikDefaults{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	mikrotikProvider := &MikrotikProvider{
+		clients:      []*MikrotikApiClient{client},
+		defaults:     &MikrotikDefaults{},
+		domainFilter: endpoint.NewDomainFilter([]string{"example.com"}),
+	}
+
+	endpoints, err := mikrotikProvider.Records(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("rr.example.com", "A", 3600, "192.0.2.1", "192.0.2.2"),
+		endpoint.NewEndpointWithTTL("other.example.com", "CNAME", 3600, "rr.example.com"),
+		endpoint.NewEndpointWithTTL("rr.example.com", "AAAA", 3600, "2001:db8::1"),
+	}
+	if len(endpoints) != len(expected) {
+		t.Fatalf("Expected %d endpoints, got %d: %v", len(expected), len(endpoints), endpoints)
+	}
+	for i := range expected {
+		if endpoints[i].RecordType != expected[i].RecordType || !mikrotikProvider.compareEndpoints(endpoints[i], expected[i]) {
+			t.Errorf("Expected endpoint: %v , got %v", expected[i], endpoints[i])
+		}
+	}
+}
+
+func TestAdjustEndpoints(t *testing.T) {
+	mikrotikProvider := &MikrotikProvider{
+		defaults: &MikrotikDefaults{
+			DefaultTTL: defaultTTL,
+		},
+	}
+	tests := []struct {
+		name     string
+		input    []*endpoint.Endpoint
+		expected []*endpoint.Endpoint
+	}{
+		{
+			name:     "NXDOMAIN empty target is removed",
+			input:    []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("blocked.example.com", "NXDOMAIN", 3600, "")},
+			expected: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("blocked.example.com", "NXDOMAIN", 3600)},
+		},
+		{
+			name:     "Other record types are left untouched",
+			input:    []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.example.com", "A", 3600, "192.0.2.1")},
+			expected: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.example.com", "A", 3600, "192.0.2.1")},
+		},
+		{
+			name:     "Default TTL is applied",
+			input:    []*endpoint.Endpoint{endpoint.NewEndpoint("a.example.com", "A", "192.0.2.1")},
+			expected: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.example.com", "A", defaultTTL, "192.0.2.1")},
+		},
+		{
+			name:     "Names are lowercased without trailing dot",
+			input:    []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("Web.Example.com.", "CNAME", 3600, "Target.Example.com.")},
+			expected: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("web.example.com", "CNAME", 3600, "target.example.com")},
+		},
+		{
+			name:     "IPv6 addresses are canonicalized",
+			input:    []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.example.com", "AAAA", 3600, "2001:0DB8:0000::0001", "2001:db8::1", "2001:db8::2")},
+			expected: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.example.com", "AAAA", 3600, "2001:db8::1", "2001:db8::2")},
+		},
+		{
+			name:     "MX and SRV targets are normalized",
+			input:    []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("example.com", "MX", 3600, "10  Mail.Example.com."), endpoint.NewEndpointWithTTL("_sip._tcp.example.com", "SRV", 3600, "10 5 5060 sip.example.com.")},
+			expected: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("example.com", "MX", 3600, "10 mail.example.com"), endpoint.NewEndpointWithTTL("_sip._tcp.example.com", "SRV", 3600, "10 5 5060 sip.example.com")},
+		},
+		{
+			name:     "Invalid targets are dropped",
+			input:    []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.example.com", "A", 3600, "192.0.2.1", "not-an-ip", "2001:db8::1"), endpoint.NewEndpointWithTTL("example.com", "MX", 3600, "mail.example.com")},
+			expected: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.example.com", "A", 3600, "192.0.2.1")},
+		},
+		{
+			name:     "Unsupported record types are dropped",
+			input:    []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("example.com", "CAA", 3600, "0 issue \"ca.example.net\""), endpoint.NewEndpointWithTTL("a.example.com", "A", 3600, "192.0.2.1")},
+			expected: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.example.com", "A", 3600, "192.0.2.1")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adjusted, err := mikrotikProvider.AdjustEndpoints(tt.input)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(adjusted) != len(tt.expected) {
+				t.Fatalf("Expected %d endpoints, got %d", len(tt.expected), len(adjusted))
+			}
+			for i := range tt.expected {
+				if !slices.Equal(adjusted[i].Targets, tt.expected[i].Targets) || adjusted[i].RecordTTL != tt.expected[i].RecordTTL || !mikrotikProvider.compareEndpoints(adjusted[i], tt.expected[i]) {
+					t.Errorf("Expected endpoint: %v , got %v", tt.expected[i], adjusted[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAdjustEndpointsRegexpNames(t *testing.T) {
+	pattern := ".*\\.ads\\.com"
+	mikrotikProvider := &MikrotikProvider{
+		defaults: &MikrotikDefaults{DefaultTTL: defaultTTL},
+		config:   &MikrotikProviderConfig{RegexpNameSuffix: "regexp.example.com"},
+	}
+
+	adjusted, err := mikrotikProvider.AdjustEndpoints([]*endpoint.Endpoint{
+		endpoint.NewEndpoint("ads.regexp.example.com", "NXDOMAIN").WithProviderSpecific("regexp", pattern),
+		endpoint.NewEndpoint("a.example.com", "A", "192.0.2.1"),
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(adjusted) != 2 {
+		t.Fatalf("Expected 2 endpoints, got %d: %v", len(adjusted), adjusted)
+	}
+	if expectedName := regexpRecordName(pattern, "regexp.example.com"); adjusted[0].DNSName != expectedName {
+		t.Errorf("Expected the regexp record to be renamed to %s, got %s", expectedName, adjusted[0].DNSName)
+	}
+	if adjusted[1].DNSName != "a.example.com" {
+		t.Errorf("Expected the regular record to keep its name, got %s", adjusted[1].DNSName)
+	}
+
+	// the renamed endpoint can be applied as it is
+	if _, err := mikrotikProvider.routerEndpoints(adjusted); err != nil {
+		t.Errorf("Expected the adjusted endpoints to be mapped to router endpoints, got %v", err)
+	}
+}
+
+func TestRecordsRegexpNames(t *testing.T) {
+	records := []DNSRecord{
+		{ID: "*1", Regexp: ".*\\.ads\\.com", Type: "NXDOMAIN", TTL: "1h"},
+		{ID: "*2", Name: "a.example.com", Address: "192.0.2.1", TTL: "1h"},
+	}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == "/rest/ip/dns/static" {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(records); err != nil {
+				t.Errorf("error json encoding dns records")
+			}
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	client, err := NewMikrotikClient(&MikrotikConnectionConfig{BaseUrls: []string{server.URL}, SkipTLSVerify: true}, &MikrotikDefaults{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	mikrotikProvider := &MikrotikProvider{
+		clients:      []*MikrotikApiClient{client},
+		defaults:     &MikrotikDefaults{},
+		domainFilter: endpoint.NewDomainFilter([]string{"regexp.example.com"}),
+		config:       &MikrotikProviderConfig{RegexpNameSuffix: "regexp.example.com"},
+	}
+
+	endpoints, err := mikrotikProvider.Records(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(endpoints) != 1 {
+		t.Fatalf("Expected 1 endpoint, got %d: %v", len(endpoints), endpoints)
+	}
+
+	expectedName := regexpRecordName(".*\\.ads\\.com", "regexp.example.com")
+	if endpoints[0].DNSName != expectedName {
+		t.Errorf("Expected DNSName %s, got %s", expectedName, endpoints[0].DNSName)
+	}
+	if regexp, _ := endpoints[0].GetProviderSpecificProperty("regexp"); regexp != ".*\\.ads\\.com" {
+		t.Errorf("Expected regexp property to be kept, got %s", regexp)
+	}
+}
+
+func TestRouterEndpoints(t *testing.T) {
+	suffix := "regexp.example.com"
+	pattern := ".*\\.ads\\.com"
+	tests := []struct {
+		name          string
+		config        *MikrotikProviderConfig
+		input         *endpoint.Endpoint
+		expectedName  string
+		expectedError bool
+	}{
+		{
+			name:         "Synthetic name is mapped back to a nameless regexp record",
+			config:       &MikrotikProviderConfig{RegexpNameSuffix: suffix},
+			input:        endpoint.NewEndpoint(regexpRecordName(pattern, suffix), "NXDOMAIN").WithProviderSpecific("regexp", pattern),
+			expectedName: "",
+		},
+		{
+			name:         "Regular records are left untouched",
+			config:       &MikrotikProviderConfig{RegexpNameSuffix: suffix},
+			input:        endpoint.NewEndpoint("a.example.com", "A", "192.0.2.1"),
+			expectedName: "a.example.com",
+		},
+		{
+			name:          "Regexp record with a name not matching its pattern",
+			config:        &MikrotikProviderConfig{RegexpNameSuffix: suffix},
+			input:         endpoint.NewEndpoint("ads.example.com", "NXDOMAIN").WithProviderSpecific("regexp", pattern),
+			expectedError: true,
+		},
+		{
+			name:         "Regexp names disabled",
+			config:       &MikrotikProviderConfig{},
+			input:        endpoint.NewEndpoint("ads.example.com", "NXDOMAIN").WithProviderSpecific("regexp", pattern),
+			expectedName: "ads.example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mikrotikProvider := &MikrotikProvider{config: tt.config}
+			endpoints, err := mikrotikProvider.routerEndpoints([]*endpoint.Endpoint{tt.input})
+
+			if tt.expectedError {
+				if err == nil {
+					t.Fatalf("Expected error, got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if endpoints[0].DNSName != tt.expectedName {
+				t.Errorf("Expected DNSName '%s', got '%s'", tt.expectedName, endpoints[0].DNSName)
+			}
+		})
+	}
+}
+
+// mockRouter is an in-memory RouterOS static DNS table served over the REST API
+type mockRouter struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	records []DNSRecord
+	nextID  int
+	failing bool
+
+	// Records with this address are rejected when created or updated
+	rejectAddress string
+
+	// The fields of all PATCH requests received
+	patches []map[string]string
+
+	// The method and name or ID of all requests changing records, in the order they were received
+	writes []string
+}
+
+func newMockRouter(t *testing.T, records ...DNSRecord) *mockRouter {
+	router := &mockRouter{records: records, nextID: 100}
+	router.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router.mu.Lock()
+		defer router.mu.Unlock()
+
+		if router.failing {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		switch {
+		case r.URL.Path == "/rest/system/resource" && r.Method == http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(MikrotikSystemInfo{BoardName: "mock", Version: "7.16 (stable)"}); err != nil {
+				t.Errorf("error json encoding system info")
+			}
+
+		case r.URL.Path == "/rest/system/identity" && r.Method == http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(MikrotikSystemIdentity{Name: "mock-router"}); err != nil {
+				t.Errorf("error json encoding system identity")
+			}
+
+		case r.URL.Path == "/rest/ip/dns/static" && r.Method == http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(router.records); err != nil {
+				t.Errorf("error json encoding dns records")
+			}
+
+		case r.URL.Path == "/rest/ip/dns/static" && r.Method == http.MethodPut:
+			var record DNSRecord
+			if err := json.NewDecoder(r.Body).Decode(&record); err != nil || (record.Address != "" && record.Address == router.rejectAddress) {
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			}
+			router.nextID++
+			record.ID = fmt.Sprintf("*%X", router.nextID)
+			router.records = append(router.records, record)
+			router.writes = append(router.writes, fmt.Sprintf("PUT %s %s", record.Name, defaultValue(record.Type, "A")))
+
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(record); err != nil {
+				t.Errorf("error json encoding dns record")
+			}
+
+		case strings.HasPrefix(r.URL.Path, "/rest/ip/dns/static/") && r.Method == http.MethodPatch:
+			id := strings.TrimPrefix(r.URL.Path, "/rest/ip/dns/static/")
+			var fields map[string]string
+			if err := json.NewDecoder(r.Body).Decode(&fields); err != nil || (fields["address"] != "" && fields["address"] == router.rejectAddress) {
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			}
+			for i, record := range router.records {
+				if record.ID != id {
+					continue
+				}
+				router.patches = append(router.patches, fields)
+				router.writes = append(router.writes, fmt.Sprintf("PATCH %s %s", record.Name, defaultValue(record.Type, "A")))
+
+				// Apply the fields on the JSON representation of the record
+				data, _ := json.Marshal(record)
+				current := map[string]string{}
+				_ = json.Unmarshal(data, &current)
+				maps.Copy(current, fields)
+				data, _ = json.Marshal(current)
+				updated := DNSRecord{}
+				_ = json.Unmarshal(data, &updated)
+				router.records[i] = updated
+
+				w.Header().Set("Content-Type", "application/json")
+				if err := json.NewEncoder(w).Encode(updated); err != nil {
+					t.Errorf("error json encoding dns record")
+				}
+				return
+			}
+			http.Error(w, "Not Found", http.StatusNotFound)
+
+		case strings.HasPrefix(r.URL.Path, "/rest/ip/dns/static/") && r.Method == http.MethodDelete:
+			id := strings.TrimPrefix(r.URL.Path, "/rest/ip/dns/static/")
+			for i, record := range router.records {
+				if record.ID == id {
+					router.writes = append(router.writes, fmt.Sprintf("DELETE %s %s", record.Name, defaultValue(record.Type, "A")))
+					router.records = append(router.records[:i], router.records[i+1:]...)
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+			}
+			http.Error(w, "Not Found", http.StatusNotFound)
+
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(router.Close)
+	return router
+}
+
+func (m *mockRouter) client(t *testing.T, name string) *MikrotikApiClient {
+	client, err := NewMikrotikClient(&MikrotikConnectionConfig{Name: name, BaseUrls: []string{m.URL}, SkipTLSVerify: true}, &MikrotikDefaults{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return client
+}
+
+func (m *mockRouter) setFailing(failing bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failing = failing
+}
+
+func (m *mockRouter) setRejectAddress(address string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rejectAddress = address
+}
+
+func (m *mockRouter) addresses() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var addresses []string
+	for _, record := range m.records {
+		addresses = append(addresses, record.Address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+func (m *mockRouter) comments() map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	comments := map[string]string{}
+	for _, record := range m.records {
+		comments[record.Name] = record.Comment
+	}
+	return comments
+}
+
+func (m *mockRouter) writeLog() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.writes)
+}
+
+func TestMultipleRouters(t *testing.T) {
+	first := newMockRouter(t,
+		DNSRecord{ID: "*1", Name: "a.example.com", Address: "192.0.2.1", TTL: "1h"},
+		DNSRecord{ID: "*2", Name: "b.example.com", Address: "192.0.2.2", TTL: "1h"},
+	)
+	second := newMockRouter(t,
+		DNSRecord{ID: "*1", Name: "a.example.com", Address: "192.0.2.1", TTL: "1h"},
+	)
+	third := newMockRouter(t,
+		DNSRecord{ID: "*1", Name: "a.example.com", Address: "192.0.2.1", TTL: "1h"},
+		DNSRecord{ID: "*2", Name: "b.example.com", Address: "192.0.2.2", TTL: "1h"},
+	)
+
+	mikrotikProvider := &MikrotikProvider{
+		clients:      []*MikrotikApiClient{first.client(t, "first"), second.client(t, "second"), third.client(t, "third")},
+		defaults:     &MikrotikDefaults{DefaultTTL: 3600},
+		domainFilter: endpoint.NewDomainFilter([]string{"example.com"}),
+	}
+
+	// Records are merged and diverged routers are flagged
+	endpoints, err := mikrotikProvider.Records(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(endpoints) != 2 {
+		t.Fatalf("Expected 2 merged endpoints, got %d: %v", len(endpoints), endpoints)
+	}
+	if diverged := mikrotikProvider.DivergedRouters(); len(diverged) != 1 || diverged[0] != "second" {
+		t.Errorf("Expected only 'second' to be flagged as diverged, got %v", diverged)
+	}
+
+	// Changes are written to all routers
+	err = mikrotikProvider.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("c.example.com", "A", 3600, "192.0.2.3")},
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.example.com", "A", 3600, "192.0.2.1")},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, router := range []*mockRouter{first, third} {
+		if addresses := router.addresses(); strings.Join(addresses, ",") != "192.0.2.2,192.0.2.3" {
+			t.Errorf("Expected records 192.0.2.2 and 192.0.2.3, got %v", addresses)
+		}
+	}
+	// The record missed by the diverged router is only reported
+	if addresses := second.addresses(); strings.Join(addresses, ",") != "192.0.2.3" {
+		t.Errorf("Expected record 192.0.2.3, got %v", addresses)
+	}
+
+	// Errors are reported per router, without stopping the changes on the other routers
+	second.setFailing(true)
+	err = mikrotikProvider.ApplyChanges(context.Background(), &plan.Changes{
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("b.example.com", "A", 3600, "192.0.2.2")},
+	})
+	if err == nil {
+		t.Fatalf("Expected error, got none")
+	}
+	if !strings.Contains(err.Error(), "second") || strings.Contains(err.Error(), "first") {
+		t.Errorf("Expected the error to only mention the 'second' router, got: %v", err)
+	}
+	for _, router := range []*mockRouter{first, third} {
+		if addresses := router.addresses(); strings.Join(addresses, ",") != "192.0.2.3" {
+			t.Errorf("Expected record 192.0.2.3, got %v", addresses)
+		}
+	}
+}
+
+func TestDivergedRouterRepair(t *testing.T) {
+	first := newMockRouter(t,
+		DNSRecord{ID: "*1", Name: "a.example.com", Address: "192.0.2.1", TTL: "1h"},
+		DNSRecord{ID: "*2", Name: "b.example.com", Address: "192.0.2.2", TTL: "1h"},
+		DNSRecord{ID: "*3", Name: "c.example.com", Address: "192.0.2.3", TTL: "1h"},
+		DNSRecord{ID: "*4", Name: "d.example.com", Address: "192.0.2.4", TTL: "1h"},
+	)
+	second := newMockRouter(t,
+		DNSRecord{ID: "*1", Name: "a.example.com", Address: "192.0.2.1", TTL: "1h"},
+		DNSRecord{ID: "*5", Name: "d.example.com", Address: "192.0.2.5", TTL: "1h"},
+	)
+
+	mikrotikProvider := &MikrotikProvider{
+		clients:      []*MikrotikApiClient{first.client(t, "first"), second.client(t, "second")},
+		defaults:     &MikrotikDefaults{DefaultTTL: 3600},
+		domainFilter: endpoint.NewDomainFilter([]string{"example.com"}),
+	}
+
+	endpoints, err := mikrotikProvider.Records(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, ep := range endpoints {
+		if ep.DNSName == "d.example.com" && !sameTargets(ep.Targets, endpoint.Targets{"192.0.2.4", "192.0.2.5"}) {
+			t.Errorf("Expected the targets of all routers to be merged, got %v", ep.Targets)
+		}
+	}
+	statuses := mikrotikProvider.Status().([]RouterStatus)
+	// Each router misses a record or target of the other one
+	if !statuses[0].Diverged || !statuses[1].Diverged {
+		t.Errorf("Expected both routers to be reported as diverged, got %+v", statuses)
+	}
+
+	// The missing record to delete is skipped, the missing record to update is created and the extra target is deleted,
+	// while the missing target left as it is is not created
+	err = mikrotikProvider.ApplyChanges(context.Background(), &plan.Changes{
+		Delete:    []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("b.example.com", "A", 3600, "192.0.2.2")},
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("c.example.com", "A", 3600, "192.0.2.3"), endpoint.NewEndpointWithTTL("d.example.com", "A", 3600, "192.0.2.4", "192.0.2.5")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("c.example.com", "A", 3600, "192.0.2.6"), endpoint.NewEndpointWithTTL("d.example.com", "A", 3600, "192.0.2.4")},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if addresses := first.addresses(); strings.Join(addresses, ",") != "192.0.2.1,192.0.2.4,192.0.2.6" {
+		t.Errorf("Expected records 192.0.2.1, 192.0.2.4 and 192.0.2.6, got %v", addresses)
+	}
+	if addresses := second.addresses(); strings.Join(addresses, ",") != "192.0.2.1,192.0.2.6" {
+		t.Errorf("Expected records 192.0.2.1 and 192.0.2.6, got %v", addresses)
+	}
+
+	if _, err := mikrotikProvider.Records(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if diverged := mikrotikProvider.DivergedRouters(); len(diverged) != 1 || diverged[0] != "second" {
+		t.Errorf("Expected only 'second' to still be diverged, got %v", diverged)
+	}
+}
+
+func TestDivergedRouterRepairEnabled(t *testing.T) {
+	owned := ownerMarker("default")
+	first := newMockRouter(t,
+		DNSRecord{ID: "*1", Name: "a.example.com", Address: "192.0.2.1", TTL: "1h", Comment: owned},
+		DNSRecord{ID: "*2", Name: "b.example.com", Address: "192.0.2.2", TTL: "1h", Comment: owned},
+		DNSRecord{ID: "*3", Name: "c.example.com", Address: "192.0.2.3", TTL: "1h", Comment: "by hand"},
+	)
+	second := newMockRouter(t,
+		DNSRecord{ID: "*1", Name: "a.example.com", Address: "192.0.2.1", TTL: "1h", Comment: owned},
+	)
+
+	clients := []*MikrotikApiClient{first.client(t, "first"), second.client(t, "second")}
+	for _, client := range clients {
+		client.ownerMarker = owned
+	}
+	mikrotikProvider := &MikrotikProvider{
+		clients:      clients,
+		defaults:     &MikrotikDefaults{DefaultTTL: 3600},
+		domainFilter: endpoint.NewDomainFilter([]string{"example.com"}),
+		config:       &MikrotikProviderConfig{OwnerID: "default", RepairDivergedRouters: true},
+	}
+
+	if _, err := mikrotikProvider.Records(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := mikrotikProvider.ApplyChanges(context.Background(), &plan.Changes{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// only the owned record missed by the diverged router is created on it
+	if addresses := second.addresses(); strings.Join(addresses, ",") != "192.0.2.1,192.0.2.2" {
+		t.Errorf("Expected records 192.0.2.1 and 192.0.2.2, got %v", addresses)
+	}
+	if _, err := mikrotikProvider.Records(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if diverged := mikrotikProvider.DivergedRouters(); len(diverged) != 0 {
+		t.Errorf("Expected the routers to be in sync again, got %v diverged", diverged)
+	}
+}
+
+func TestInconsistentRecords(t *testing.T) {
+	router := newMockRouter(t,
+		DNSRecord{ID: "*1", Name: "a.example.com", Address: "192.0.2.1", TTL: "1h"},
+		DNSRecord{ID: "*2", Name: "a.example.com", Address: "192.0.2.2", TTL: "5m", Comment: "stale"},
+		DNSRecord{ID: "*3", Name: "a.example.com", Address: "192.0.2.3", TTL: "1h", MatchSubdomain: "true"},
+	)
+	mikrotikProvider := &MikrotikProvider{
+		clients:      []*MikrotikApiClient{router.client(t, "inconsistent-records")},
+		defaults:     &MikrotikDefaults{DefaultTTL: 3600},
+		domainFilter: endpoint.NewDomainFilter([]string{"example.com"}),
+	}
+
+	endpoints, err := mikrotikProvider.Records(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(endpoints) != 2 || len(endpoints[0].Targets) != 2 || endpoints[0].RecordTTL != 3600 {
+		t.Fatalf("Expected the entries of a.example.com to be grouped with the properties of the first one, got %v", endpoints)
+	}
+	if matchSubdomain, _ := endpoints[1].GetProviderSpecificProperty("match-subdomain"); endpoints[1].Targets[0] != "192.0.2.3" || matchSubdomain != "true" {
+		t.Errorf("Expected the entry matching subdomains to be a separate endpoint, got %v", endpoints[1])
+	}
+	if count := testutil.ToFloat64(inconsistentRecordsGauge.WithLabelValues("inconsistent-records")); count != 1 {
+		t.Errorf("Expected 1 inconsistent record, got %v", count)
+	}
+
+	// the inconsistent entry is only reported, changes of other endpoints leave it as it is
+	err = mikrotikProvider.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("b.example.com", "A", 3600, "192.0.2.4")},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := []string{"PUT b.example.com A"}
+	if writes := router.writeLog(); !slices.Equal(writes, expected) {
+		t.Errorf("Expected requests %v, got %v", expected, writes)
+	}
+}
+
+func TestApplyChangesRollback(t *testing.T) {
+	router := newMockRouter(t,
+		DNSRecord{ID: "*1", Name: "a.example.com", Address: "192.0.2.1", TTL: "1h", Comment: "keep me"},
+		DNSRecord{ID: "*2", Name: "b.example.com", Address: "192.0.2.2", TTL: "1h"},
+	)
+	router.setRejectAddress("192.0.2.5")
+
+	mikrotikProvider := &MikrotikProvider{
+		clients:      []*MikrotikApiClient{router.client(t, "router")},
+		defaults:     &MikrotikDefaults{DefaultTTL: 3600},
+		domainFilter: endpoint.NewDomainFilter([]string{"example.com"}),
+	}
+
+	err := mikrotikProvider.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("c.example.com", "A", 3600, "192.0.2.3", "192.0.2.4"),
+			endpoint.NewEndpointWithTTL("d.example.com", "A", 3600, "192.0.2.6", "192.0.2.5"),
+		},
+		Delete:    []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.example.com", "A", 3600, "192.0.2.1")},
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("b.example.com", "A", 3600, "192.0.2.2")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("b.example.com", "A", 3600, "192.0.2.7")},
+	})
+	if err == nil {
+		t.Fatalf("Expected error, got none")
+	}
+
+	var txErr *TransactionError
+	if !errors.As(err, &txErr) {
+		t.Fatalf("Expected a *TransactionError, got %T: %v", err, err)
+	}
+	if !txErr.RolledBack() {
+		t.Errorf("Expected the transaction to be rolled back, got: %v", txErr)
+	}
+	if len(txErr.Deleted) != 1 || len(txErr.Updated) != 1 || len(txErr.Created) != 3 {
+		t.Errorf("Expected 1 deleted, 1 updated and 3 created records, got %d, %d and %d", len(txErr.Deleted), len(txErr.Updated), len(txErr.Created))
+	}
+	if len(txErr.Restored) != 1 || len(txErr.Reverted) != 1 || len(txErr.Removed) != 3 {
+		t.Errorf("Expected 1 restored, 1 reverted and 3 removed records, got %d, %d and %d", len(txErr.Restored), len(txErr.Reverted), len(txErr.Removed))
+	}
+
+	if addresses := router.addresses(); strings.Join(addresses, ",") != "192.0.2.1,192.0.2.2" {
+		t.Errorf("Expected the original records to be left, got %v", addresses)
+	}
+	if restored := txErr.Restored[0]; restored.Name != "a.example.com" || restored.Comment != "keep me" || restored.TTL != "1h" {
+		t.Errorf("Expected the deleted record to be restored with its properties, got %+v", restored)
+	}
+}
+
+func TestApplyChangesIncompleteRollback(t *testing.T) {
+	router := newMockRouter(t,
+		DNSRecord{ID: "*1", Name: "a.example.com", Address: "192.0.2.1", TTL: "1h"},
+	)
+	// Restoring the deleted record fails as well
+	router.setRejectAddress("192.0.2.1")
+
+	mikrotikProvider := &MikrotikProvider{
+		clients:      []*MikrotikApiClient{router.client(t, "router")},
+		defaults:     &MikrotikDefaults{DefaultTTL: 3600},
+		domainFilter: endpoint.NewDomainFilter([]string{"example.com"}),
+	}
+
+	err := mikrotikProvider.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("b.example.com", "A", 3600, "192.0.2.1")},
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("a.example.com", "A", 3600, "192.0.2.1")},
+	})
+
+	var txErr *TransactionError
+	if !errors.As(err, &txErr) {
+		t.Fatalf("Expected a *TransactionError, got %T: %v", err, err)
+	}
+	if txErr.RolledBack() || len(txErr.RollbackErrs) != 1 {
+		t.Errorf("Expected the rollback to be incomplete with 1 error, got: %v", txErr)
+	}
+	if !strings.Contains(err.Error(), "rollback incomplete") {
+		t.Errorf("Expected the error to report the incomplete rollback, got: %v", err)
+	}
+}
+
+func TestApplyChangesUpdatesInPlace(t *testing.T) {
+	router := newMockRouter(t,
+		DNSRecord{ID: "*1", Name: "a.example.com", Address: "192.0.2.1", TTL: "1h", Comment: "old"},
+		DNSRecord{ID: "*2", Name: "b.example.com", Address: "192.0.2.2", TTL: "1h"},
+	)
+
+	mikrotikProvider := &MikrotikProvider{
+		clients:      []*MikrotikApiClient{router.client(t, "router")},
+		defaults:     &MikrotikDefaults{DefaultTTL: 3600},
+		domainFilter: endpoint.NewDomainFilter([]string{"example.com"}),
+	}
+
+	err := mikrotikProvider.ApplyChanges(context.Background(), &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("a.example.com", "A", 3600, "192.0.2.1").WithProviderSpecific("comment", "old"),
+			endpoint.NewEndpointWithTTL("b.example.com", "A", 3600, "192.0.2.2"),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("a.example.com", "A", 3600, "192.0.2.1").WithProviderSpecific("comment", "new"),
+			endpoint.NewEndpointWithTTL("b.example.com", "A", 60, "192.0.2.3"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expectedPatches := []map[string]string{
+		{"comment": "new"},
+		{"ttl": "1m", "address": "192.0.2.3"},
+	}
+	if !reflect.DeepEqual(router.patches, expectedPatches) {
+		t.Errorf("Expected patches %v, got %v", expectedPatches, router.patches)
+	}
+	if router.records[0].ID != "*1" || router.records[0].Comment != "new" {
+		t.Errorf("Expected record *1 to be updated in place, got %+v", router.records[0])
+	}
+	if router.records[1].ID != "*2" || router.records[1].Address != "192.0.2.3" {
+		t.Errorf("Expected record *2 to be updated in place, got %+v", router.records[1])
+	}
+}
@@ -1,8 +1,11 @@
 package mikrotik
 
 import (
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"net"
+	"net/netip"
 	"regexp"
 	"strconv"
 	"strings"
@@ -17,7 +20,7 @@ import (
 type DNSRecord struct {
 	// Common fields for all record types
 	ID             string `json:".id,omitempty"`             // only fetched from API
-	Name           string `json:"name"`                      // endpoint.DNSName
+	Name           string `json:"name,omitempty"`            // endpoint.DNSName
 	Type           string `json:"type"`                      // endpoint.RecordType
 	TTL            string `json:"ttl,omitempty"`             // endpoint.RecordTTL
 	Comment        string `json:"comment,omitempty"`         // provider-specific
@@ -27,9 +30,9 @@ type DNSRecord struct {
 	Disabled       string `json:"disabled,omitempty"`        // provider-specific
 
 	// Record specific fields
-	Address      string `json:"address,omitempty"`       // A, AAAA -> endpoint.Targets[0]
-	CName        string `json:"cname,omitempty"`         // CNAME -> endpoint.Targets[0]
-	Text         string `json:"text,omitempty"`          // TXT -> endpoint.Targets[0]
+	Address      string `json:"address,omitempty"`       // A, AAAA -> endpoint.Targets[i]
+	CName        string `json:"cname,omitempty"`         // CNAME -> endpoint.Targets[i]
+	Text         string `json:"text,omitempty"`          // TXT -> endpoint.Targets[i]
 	MXExchange   string `json:"mx-exchange,omitempty"`   // MX -> provider-specific
 	MXPreference string `json:"mx-preference,omitempty"` // MX -> provider-specific
 	SrvPort      string `json:"srv-port,omitempty"`      // SRV -> provider-specific
@@ -37,23 +40,58 @@ type DNSRecord struct {
 	SrvPriority  string `json:"srv-priority,omitempty"`  // SRV -> provider-specific
 	SrvWeight    string `json:"srv-weight,omitempty"`    // SRV -> provider-specific
 	NS           string `json:"ns,omitempty"`            // NS -> provider-specific
+	ForwardTo    string `json:"forward-to,omitempty"`    // FWD -> endpoint.Targets[i]
+}
+
+// NewDNSRecords converts an ExternalDNS Endpoint to Mikrotik DNSRecords, one for each of its targets
+func NewDNSRecords(endpoint *endpoint.Endpoint) ([]*DNSRecord, error) {
+	if !hasTarget(endpoint.RecordType) {
+		record, err := newDNSRecord(endpoint, nonEmptyTargets(endpoint.Targets).String())
+		if err != nil {
+			return nil, err
+		}
+		return []*DNSRecord{record}, nil
+	}
 
-	// Additional fields for other record types that are not currently supported
-	// ForwardTo    string `json:"forward-to,omitempty"`    // FWD
+	if len(endpoint.Targets) == 0 {
+		return nil, fmt.Errorf("no target provided for DNS record")
+	}
+
+	records := make([]*DNSRecord, 0, len(endpoint.Targets))
+	for _, target := range endpoint.Targets {
+		record, err := newDNSRecord(endpoint, target)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
 }
 
-// NewDNSRecord converts an ExternalDNS Endpoint to a Mikrotik DNSRecord
+// NewDNSRecord converts the first target of an ExternalDNS Endpoint to a Mikrotik DNSRecord
 func NewDNSRecord(endpoint *endpoint.Endpoint) (*DNSRecord, error) {
-	log.Debugf("Converting ExternalDNS endpoint to MikrotikDNS: %+v", endpoint)
+	if !hasTarget(endpoint.RecordType) {
+		return newDNSRecord(endpoint, nonEmptyTargets(endpoint.Targets).String())
+	}
+	if len(endpoint.Targets) == 0 {
+		return nil, fmt.Errorf("no target provided for DNS record")
+	}
+	return newDNSRecord(endpoint, endpoint.Targets[0])
+}
+
+// newDNSRecord converts an ExternalDNS Endpoint to a Mikrotik DNSRecord pointing to the given target
+func newDNSRecord(endpoint *endpoint.Endpoint, target string) (*DNSRecord, error) {
+	log.Debugf("Converting ExternalDNS endpoint to MikrotikDNS: %+v (target: %s)", endpoint, target)
 
 	// Sanity checks -> Fields are not empty and if set, they are set correctly
-	if endpoint.DNSName == "" {
+	if endpoint.DNSName == "" && endpointRegexp(endpoint) == "" {
 		return nil, fmt.Errorf("DNS name is required")
 	}
 	if endpoint.RecordType == "" {
 		return nil, fmt.Errorf("record type is required")
 	}
-	if len(endpoint.Targets) == 0 || endpoint.Targets[0] == "" {
+	if target == "" && hasTarget(endpoint.RecordType) {
 		return nil, fmt.Errorf("no target provided for DNS record")
 	}
 
@@ -72,35 +110,35 @@ func NewDNSRecord(endpoint *endpoint.Endpoint) (*DNSRecord, error) {
 	// Record-type specific data
 	switch record.Type {
 	case "A":
-		if err := validateIPv4(endpoint.Targets[0]); err != nil {
+		if err := validateIPv4(target); err != nil {
 			return nil, err
 		}
-		record.Address = endpoint.Targets[0]
+		record.Address = target
 		log.Debugf("Address set to: %s", record.Address)
 
 	case "AAAA":
-		if err := validateIPv6(endpoint.Targets[0]); err != nil {
+		if err := validateIPv6(target); err != nil {
 			return nil, err
 		}
-		record.Address = endpoint.Targets[0]
+		record.Address = target
 		log.Debugf("Address set to: %s", record.Address)
 
 	case "CNAME":
-		if err := validateDomain(endpoint.Targets[0]); err != nil {
+		if err := validateDomain(target); err != nil {
 			return nil, err
 		}
-		record.CName = endpoint.Targets[0]
+		record.CName = target
 		log.Debugf("CNAME set to: %s", record.Address)
 
 	case "TXT":
-		if err := validateTXT(endpoint.Targets[0]); err != nil {
+		if err := validateTXT(target); err != nil {
 			return nil, err
 		}
-		record.Text = endpoint.Targets[0]
+		record.Text = target
 		log.Debugf("Text set to: %s", record.Text)
 
 	case "MX":
-		preference, exchange, err := parseMX(endpoint.Targets[0])
+		preference, exchange, err := parseMX(target)
 		if err != nil {
 			return nil, err
 		}
@@ -110,7 +148,7 @@ func NewDNSRecord(endpoint *endpoint.Endpoint) (*DNSRecord, error) {
 		log.Debugf("MX exchange set to: %s", record.MXExchange)
 
 	case "SRV":
-		priority, weight, port, target, err := parseSRV(endpoint.Targets[0])
+		priority, weight, port, target, err := parseSRV(target)
 		if err != nil {
 			return nil, err
 		}
@@ -124,12 +162,24 @@ func NewDNSRecord(endpoint *endpoint.Endpoint) (*DNSRecord, error) {
 		log.Debugf("SRV target set to: %s", record.SrvTarget)
 
 	case "NS":
-		if err := validateDomain(endpoint.Targets[0]); err != nil {
+		if err := validateDomain(target); err != nil {
 			return nil, err
 		}
-		record.NS = endpoint.Targets[0]
+		record.NS = target
 		log.Debugf("NS set to: %s", record.NS)
 
+	case "FWD":
+		if err := validateForwardTo(target); err != nil {
+			return nil, err
+		}
+		record.ForwardTo = target
+		log.Debugf("ForwardTo set to: %s", record.ForwardTo)
+
+	case "NXDOMAIN":
+		if target != "" {
+			return nil, fmt.Errorf("NXDOMAIN records cannot have a target: %s", target)
+		}
+
 	default:
 		return nil, fmt.Errorf("unsupported DNS type: %s", endpoint.RecordType)
 	}
@@ -262,12 +312,23 @@ func (r *DNSRecord) toExternalDNSEndpoint() (*endpoint.Endpoint, error) {
 		ep.Targets = endpoint.NewTargets(r.NS)
 		log.Debugf("NS set to: %s", r.NS)
 
+	case "FWD":
+		if err := validateForwardTo(r.ForwardTo); err != nil {
+			return nil, err
+		}
+		ep.Targets = endpoint.NewTargets(r.ForwardTo)
+		log.Debugf("ForwardTo set to: %s", r.ForwardTo)
+
+	case "NXDOMAIN":
+		ep.Targets = endpoint.NewTargets()
+		log.Debugf("NXDOMAIN records have no target")
+
 	default:
 		return nil, fmt.Errorf("unsupported DNS type: %s", ep.RecordType)
 	}
 
 	// Ensure at least one target is present and non-empty
-	if len(ep.Targets) == 0 || ep.Targets[0] == "" {
+	if hasTarget(ep.RecordType) && (len(ep.Targets) == 0 || ep.Targets[0] == "") {
 		return nil, fmt.Errorf("no target provided for DNS record")
 	}
 
@@ -309,6 +370,124 @@ func (r *DNSRecord) toExternalDNSEndpoint() (*endpoint.Endpoint, error) {
 	return &ep, nil
 }
 
+// sameTarget checks if two Mikrotik DNSRecords point to the same target, ignoring all other fields
+func (r *DNSRecord) sameTarget(o *DNSRecord) bool {
+	return sameTarget(r.Address, o.Address) &&
+		sameTarget(r.CName, o.CName) &&
+		r.Text == o.Text &&
+		r.MXPreference == o.MXPreference &&
+		sameTarget(r.MXExchange, o.MXExchange) &&
+		r.SrvPriority == o.SrvPriority &&
+		r.SrvWeight == o.SrvWeight &&
+		r.SrvPort == o.SrvPort &&
+		sameTarget(r.SrvTarget, o.SrvTarget) &&
+		sameTarget(r.NS, o.NS) &&
+		sameTarget(r.ForwardTo, o.ForwardTo)
+}
+
+// newIdentityRecord converts the fields identifying the static entry of an endpoint to a Mikrotik DNSRecord: its name,
+// type, regexp and match-subdomain, along with the given target. An empty target leaves the target fields unset.
+func newIdentityRecord(endpoint *endpoint.Endpoint, target string) (*DNSRecord, error) {
+	if target != "" || !hasTarget(endpoint.RecordType) {
+		return newDNSRecord(endpoint, target)
+	}
+
+	record := &DNSRecord{Name: endpoint.DNSName, Type: endpoint.RecordType, Regexp: endpointRegexp(endpoint)}
+	for _, providerSpecific := range endpoint.ProviderSpecific {
+		if providerSpecific.Name == "match-subdomain" || providerSpecific.Name == "webhook/match-subdomain" {
+			record.MatchSubdomain = providerSpecific.Value
+		}
+	}
+	return record, nil
+}
+
+// sameIdentity checks if two Mikrotik DNSRecords are the same static entry, by their name, type, regexp and
+// match-subdomain, and optionally their target. Unset values are compared as their RouterOS defaults.
+func (r *DNSRecord) sameIdentity(o *DNSRecord, compareTarget bool) bool {
+	return strings.EqualFold(r.Name, o.Name) &&
+		defaultValue(r.Type, "A") == defaultValue(o.Type, "A") &&
+		r.Regexp == o.Regexp &&
+		defaultValue(r.MatchSubdomain, "false") == defaultValue(o.MatchSubdomain, "false") &&
+		(!compareTarget || r.sameTarget(o))
+}
+
+// changedFields returns the fields of the wanted record that differ from the current one, keyed by their API name.
+// Fields identifying the record (ID, name, type and regexp) are left out, and fields unset in the wanted record are
+// cleared (set to false for flags), while values equivalent to the current ones (i.e. the same TTL in another format)
+// are not considered changes.
+func changedFields(current, wanted *DNSRecord) (map[string]string, error) {
+	currentFields, err := recordFields(current)
+	if err != nil {
+		return nil, err
+	}
+	wantedFields, err := recordFields(wanted)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]bool{}
+	for key := range currentFields {
+		keys[key] = true
+	}
+	for key := range wantedFields {
+		keys[key] = true
+	}
+
+	changed := map[string]string{}
+	for key := range keys {
+		currentValue, wantedValue := currentFields[key], wantedFields[key]
+
+		switch key {
+		case ".id", "name", "type", "regexp":
+			continue
+		case "ttl":
+			currentTTL, currentErr := mikrotikTTLtoEndpointTTL(currentValue)
+			wantedTTL, wantedErr := mikrotikTTLtoEndpointTTL(wantedValue)
+			if wantedValue == "" || (currentErr == nil && wantedErr == nil && currentTTL == wantedTTL) {
+				continue
+			}
+		case "disabled", "match-subdomain":
+			if (currentValue == "" || currentValue == "false") && (wantedValue == "" || wantedValue == "false") {
+				continue
+			}
+			// RouterOS rejects empty booleans, so unset flags are cleared with an explicit false
+			if wantedValue == "" {
+				wantedValue = "false"
+			}
+		case "address", "cname", "mx-exchange", "srv-target", "ns", "forward-to":
+			if sameTarget(currentValue, wantedValue) {
+				continue
+			}
+		}
+
+		if currentValue != wantedValue {
+			changed[key] = wantedValue
+		}
+	}
+
+	return changed, nil
+}
+
+// recordFields returns the fields of a record keyed by their API name, leaving out unset ones
+func recordFields(record *DNSRecord) (map[string]string, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]string
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for key, value := range fields {
+		if value == "" {
+			delete(fields, key)
+		}
+	}
+
+	return fields, nil
+}
+
 // ================================================================================================
 // UTILS
 // ================================================================================================
@@ -418,6 +597,114 @@ func endpointTTLtoMikrotikTTL(ttl endpoint.TTL) (string, error) {
 	return durationStr, nil
 }
 
+// endpointRegexp returns the regexp provider-specific property of an endpoint, if any.
+func endpointRegexp(ep *endpoint.Endpoint) string {
+	for _, providerSpecific := range ep.ProviderSpecific {
+		if providerSpecific.Name == "regexp" || providerSpecific.Name == "webhook/regexp" {
+			return providerSpecific.Value
+		}
+	}
+	return ""
+}
+
+// regexpRecordName returns the synthetic DNS name under which a regexp record is exposed to ExternalDNS.
+// The name is derived from a hash of the pattern, so it is stable across restarts and routers.
+func regexpRecordName(pattern, suffix string) string {
+	hash := sha256.Sum256([]byte(pattern))
+	return fmt.Sprintf("%x.%s", hash[:8], strings.Trim(strings.ToLower(suffix), "."))
+}
+
+// hasTarget checks if records of the given type point to a target.
+// NXDOMAIN records only exist to block a name, so they have none.
+func hasTarget(recordType string) bool {
+	return recordType != "NXDOMAIN"
+}
+
+// defaultValue returns the value, or the default one if it is unset
+func defaultValue(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// nonEmptyTargets returns the given targets without any empty ones.
+func nonEmptyTargets(targets endpoint.Targets) endpoint.Targets {
+	result := endpoint.Targets{}
+	for _, target := range targets {
+		if target != "" {
+			result = append(result, target)
+		}
+	}
+	return result
+}
+
+// sameTarget checks if two targets are equivalent. Domains are compared case-insensitively and
+// IP addresses are compared by value, since IPv6 addresses can be written in several ways.
+func sameTarget(a, b string) bool {
+	if strings.EqualFold(a, b) {
+		return true
+	}
+
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	return ipA != nil && ipB != nil && ipA.Equal(ipB)
+}
+
+// normalizeDomain returns the domain in lowercase, without its trailing dot
+func normalizeDomain(domain string) string {
+	return strings.ToLower(strings.TrimSuffix(domain, "."))
+}
+
+// normalizeTarget returns the target in the canonical form in which it is read back from a router: IP addresses are
+// formatted like RouterOS does (i.e. 2001:db8::1) and domains are normalized. MX and SRV targets are validated as well.
+func normalizeTarget(recordType, target string) (string, error) {
+	switch recordType {
+	case "A", "AAAA":
+		address, err := netip.ParseAddr(target)
+		if err != nil || address.Zone() != "" {
+			return "", fmt.Errorf("invalid IP address: %s", target)
+		}
+		if address.Is4() != (recordType == "A") {
+			return "", fmt.Errorf("invalid %s record address: %s", recordType, target)
+		}
+		return address.String(), nil
+
+	case "CNAME", "NS":
+		return normalizeDomain(target), nil
+
+	case "MX":
+		fields := strings.Fields(target)
+		if len(fields) > 0 {
+			fields[len(fields)-1] = normalizeDomain(fields[len(fields)-1])
+		}
+		preference, exchange, err := parseMX(strings.Join(fields, " "))
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s %s", preference, exchange), nil
+
+	case "SRV":
+		fields := strings.Fields(target)
+		if len(fields) > 0 {
+			fields[len(fields)-1] = normalizeDomain(fields[len(fields)-1])
+		}
+		priority, weight, port, srvTarget, err := parseSRV(strings.Join(fields, " "))
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s %s %s %s", priority, weight, port, srvTarget), nil
+
+	case "FWD":
+		if address, err := netip.ParseAddr(target); err == nil {
+			return address.String(), nil
+		}
+		return target, nil
+
+	default:
+		return target, nil
+	}
+}
+
 // validateIPv4 checks if the provided address is a valid IPv4 address.
 func validateIPv4(address string) error {
 	if net.ParseIP(address) == nil {
@@ -472,6 +759,26 @@ func validateDomain(domain string) error {
 	return nil
 }
 
+// validateForwardTo checks if the provided value is either an IP address or the name of a DNS forwarder.
+// Whether a forwarder with that name is actually configured on the router can only be checked via the API.
+func validateForwardTo(value string) error {
+	if value == "" {
+		return fmt.Errorf("FWD record forward-to cannot be empty")
+	}
+
+	if net.ParseIP(value) != nil {
+		return nil
+	}
+
+	forwarderRegex := `^[a-zA-Z0-9][a-zA-Z0-9._-]*$`
+	matched, err := regexp.MatchString(forwarderRegex, value)
+	if err != nil || !matched {
+		return fmt.Errorf("invalid forward-to, expected an IP address or a DNS forwarder name: %s", value)
+	}
+
+	return nil
+}
+
 // validateUnsignedInteger checks if the provided value is a number between 0 and 65535.
 func validateUnsignedInteger(value string) error {
 	if value == "" {
@@ -656,6 +656,123 @@ func TestDNSRecordToExternalDNSEndpoint(t *testing.T) {
 			expectError: true,
 		},
 
+		// ===============================================================
+		// FWD RECORD TEST CASES
+		// ===============================================================
+		{
+			name: "Valid FWD record (IP address)",
+			record: &DNSRecord{
+				Name:      "corp.example.com",
+				Type:      "FWD",
+				ForwardTo: "10.0.0.53",
+				TTL:       "1h",
+			},
+			expected: &endpoint.Endpoint{
+				DNSName:    "corp.example.com",
+				RecordType: "FWD",
+				Targets:    endpoint.NewTargets("10.0.0.53"),
+				RecordTTL:  endpoint.TTL(3600),
+			},
+			expectError: false,
+		},
+		{
+			name: "Valid FWD record (forwarder name) with match-subdomain",
+			record: &DNSRecord{
+				Name:           "corp.example.com",
+				Type:           "FWD",
+				ForwardTo:      "ad-dns",
+				MatchSubdomain: "true",
+				TTL:            "1h",
+			},
+			expected: &endpoint.Endpoint{
+				DNSName:    "corp.example.com",
+				RecordType: "FWD",
+				Targets:    endpoint.NewTargets("ad-dns"),
+				RecordTTL:  endpoint.TTL(3600),
+				ProviderSpecific: endpoint.ProviderSpecific{
+					{Name: "match-subdomain", Value: "true"},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "Invalid FWD record (empty forward-to)",
+			record: &DNSRecord{
+				Name: "corp.example.com",
+				Type: "FWD",
+				TTL:  "1h",
+			},
+			expected:    nil,
+			expectError: true,
+		},
+		{
+			name: "Invalid FWD record (malformed forward-to)",
+			record: &DNSRecord{
+				Name:      "corp.example.com",
+				Type:      "FWD",
+				ForwardTo: "not a forwarder!",
+				TTL:       "1h",
+			},
+			expected:    nil,
+			expectError: true,
+		},
+
+		// ===============================================================
+		// NXDOMAIN RECORD TEST CASES
+		// ===============================================================
+		{
+			name: "Valid NXDOMAIN record",
+			record: &DNSRecord{
+				Name: "blocked.example.com",
+				Type: "NXDOMAIN",
+				TTL:  "1h",
+			},
+			expected: &endpoint.Endpoint{
+				DNSName:    "blocked.example.com",
+				RecordType: "NXDOMAIN",
+				Targets:    endpoint.NewTargets(),
+				RecordTTL:  endpoint.TTL(3600),
+			},
+			expectError: false,
+		},
+		{
+			name: "Valid NXDOMAIN record with match-subdomain",
+			record: &DNSRecord{
+				Name:           "blocked.example.com",
+				Type:           "NXDOMAIN",
+				MatchSubdomain: "true",
+				TTL:            "1h",
+			},
+			expected: &endpoint.Endpoint{
+				DNSName:    "blocked.example.com",
+				RecordType: "NXDOMAIN",
+				Targets:    endpoint.NewTargets(),
+				RecordTTL:  endpoint.TTL(3600),
+				ProviderSpecific: endpoint.ProviderSpecific{
+					{Name: "match-subdomain", Value: "true"},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "Valid NXDOMAIN record with regexp",
+			record: &DNSRecord{
+				Regexp: ".*\\.ads\\.example\\.com",
+				Type:   "NXDOMAIN",
+				TTL:    "1h",
+			},
+			expected: &endpoint.Endpoint{
+				DNSName:    "",
+				RecordType: "NXDOMAIN",
+				Targets:    endpoint.NewTargets(),
+				RecordTTL:  endpoint.TTL(3600),
+				ProviderSpecific: endpoint.ProviderSpecific{
+					{Name: "regexp", Value: ".*\\.ads\\.example\\.com"},
+				},
+			},
+			expectError: false,
+		},
+
 		// ===============================================================
 		// PROVIDER-SPECIFIC DATA TEST CASES
 		// ===============================================================
@@ -785,7 +902,7 @@ func TestDNSRecordToExternalDNSEndpoint(t *testing.T) {
 			name: "Unsupported record type",
 			record: &DNSRecord{
 				Name: "example.com",
-				Type: "FWD",
+				Type: "INVALID",
 				TTL:  "1h",
 			},
 			expected:    nil,
@@ -1287,6 +1404,105 @@ func TestExternalDNSEndpointToDNSRecord(t *testing.T) {
 			expectError: true,
 		},
 
+		// ===============================================================
+		// FWD RECORD TEST CASES
+		// ===============================================================
+		{
+			name: "Valid FWD record (IPv6 address)",
+			endpoint: &endpoint.Endpoint{
+				DNSName:    "corp.example.com",
+				RecordType: "FWD",
+				Targets:    endpoint.NewTargets("2001:db8::53"),
+				RecordTTL:  endpoint.TTL(3600),
+			},
+			expected: &DNSRecord{
+				Name:      "corp.example.com",
+				Type:      "FWD",
+				ForwardTo: "2001:db8::53",
+				TTL:       "1h",
+			},
+			expectError: false,
+		},
+		{
+			name: "Valid FWD record (forwarder name) with match-subdomain",
+			endpoint: &endpoint.Endpoint{
+				DNSName:    "corp.example.com",
+				RecordType: "FWD",
+				Targets:    endpoint.NewTargets("ad-dns"),
+				RecordTTL:  endpoint.TTL(3600),
+				ProviderSpecific: endpoint.ProviderSpecific{
+					{Name: "match-subdomain", Value: "true"},
+				},
+			},
+			expected: &DNSRecord{
+				Name:           "corp.example.com",
+				Type:           "FWD",
+				ForwardTo:      "ad-dns",
+				MatchSubdomain: "true",
+				TTL:            "1h",
+			},
+			expectError: false,
+		},
+		{
+			name: "Invalid FWD record (malformed forward-to)",
+			endpoint: &endpoint.Endpoint{
+				DNSName:    "corp.example.com",
+				RecordType: "FWD",
+				Targets:    endpoint.NewTargets("ad dns"),
+				RecordTTL:  endpoint.TTL(3600),
+			},
+			expected:    nil,
+			expectError: true,
+		},
+
+		// ===============================================================
+		// NXDOMAIN RECORD TEST CASES
+		// ===============================================================
+		{
+			name: "Valid NXDOMAIN record (no targets)",
+			endpoint: &endpoint.Endpoint{
+				DNSName:    "blocked.example.com",
+				RecordType: "NXDOMAIN",
+				RecordTTL:  endpoint.TTL(3600),
+			},
+			expected: &DNSRecord{
+				Name: "blocked.example.com",
+				Type: "NXDOMAIN",
+				TTL:  "1h",
+			},
+			expectError: false,
+		},
+		{
+			name: "Valid NXDOMAIN record (empty target) with match-subdomain",
+			endpoint: &endpoint.Endpoint{
+				DNSName:    "blocked.example.com",
+				RecordType: "NXDOMAIN",
+				Targets:    endpoint.NewTargets(""),
+				RecordTTL:  endpoint.TTL(3600),
+				ProviderSpecific: endpoint.ProviderSpecific{
+					{Name: "match-subdomain", Value: "true"},
+				},
+			},
+			expected: &DNSRecord{
+				Name:           "blocked.example.com",
+				Type:           "NXDOMAIN",
+				MatchSubdomain: "true",
+				TTL:            "1h",
+			},
+			expectError: false,
+		},
+		{
+			name: "Invalid NXDOMAIN record (with target)",
+			endpoint: &endpoint.Endpoint{
+				DNSName:    "blocked.example.com",
+				RecordType: "NXDOMAIN",
+				Targets:    endpoint.NewTargets("192.0.2.1"),
+				RecordTTL:  endpoint.TTL(3600),
+			},
+			expected:    nil,
+			expectError: true,
+		},
+
 		// ===============================================================
 		// PROVIDER-SPECIFIC DATA TEST CASES
 		// ===============================================================
@@ -1456,7 +1672,7 @@ func TestExternalDNSEndpointToDNSRecord(t *testing.T) {
 			name: "Unsupported record type",
 			endpoint: &endpoint.Endpoint{
 				DNSName:    "unsupported.example.com",
-				RecordType: "FWD",
+				RecordType: "INVALID",
 				Targets:    endpoint.NewTargets("example.com"),
 				RecordTTL:  endpoint.TTL(3600),
 			},
@@ -1519,6 +1735,8 @@ func TestExternalDNSEndpointToDNSRecord(t *testing.T) {
 					assert.Equal(t, tt.expected.CName, record.CName)
 				case "TXT":
 					assert.Equal(t, tt.expected.Text, record.Text)
+				case "FWD":
+					assert.Equal(t, tt.expected.ForwardTo, record.ForwardTo)
 				}
 
 				// Check provider-specific properties
@@ -1530,3 +1748,146 @@ func TestExternalDNSEndpointToDNSRecord(t *testing.T) {
 		})
 	}
 }
+
+func TestExternalDNSEndpointToDNSRecords(t *testing.T) {
+	tests := []struct {
+		name        string
+		endpoint    *endpoint.Endpoint
+		expected    []*DNSRecord
+		expectError bool
+	}{
+		{
+			name:     "Single target",
+			endpoint: endpoint.NewEndpointWithTTL("example.com", "A", 3600, "192.0.2.1"),
+			expected: []*DNSRecord{
+				{Name: "example.com", Type: "A", Address: "192.0.2.1", TTL: "1h"},
+			},
+		},
+		{
+			name:     "Multiple A targets",
+			endpoint: endpoint.NewEndpointWithTTL("multi.example.com", "A", 3600, "192.0.2.1", "192.0.2.2"),
+			expected: []*DNSRecord{
+				{Name: "multi.example.com", Type: "A", Address: "192.0.2.1", TTL: "1h"},
+				{Name: "multi.example.com", Type: "A", Address: "192.0.2.2", TTL: "1h"},
+			},
+		},
+		{
+			name:     "Multiple MX targets",
+			endpoint: endpoint.NewEndpointWithTTL("example.com", "MX", 60, "10 mx1.example.com", "20 mx2.example.com"),
+			expected: []*DNSRecord{
+				{Name: "example.com", Type: "MX", MXPreference: "10", MXExchange: "mx1.example.com", TTL: "1m"},
+				{Name: "example.com", Type: "MX", MXPreference: "20", MXExchange: "mx2.example.com", TTL: "1m"},
+			},
+		},
+		{
+			name: "Provider-specific properties are set on every record",
+			endpoint: endpoint.NewEndpointWithTTL("multi.example.com", "AAAA", 3600, "2001:db8::1", "2001:db8::2").
+				WithProviderSpecific("comment", "round-robin"),
+			expected: []*DNSRecord{
+				{Name: "multi.example.com", Type: "AAAA", Address: "2001:db8::1", TTL: "1h", Comment: "round-robin"},
+				{Name: "multi.example.com", Type: "AAAA", Address: "2001:db8::2", TTL: "1h", Comment: "round-robin"},
+			},
+		},
+		{
+			name:     "Regexp record without name",
+			endpoint: endpoint.NewEndpointWithTTL("", "NXDOMAIN", 3600).WithProviderSpecific("regexp", ".*\\.ads\\.com"),
+			expected: []*DNSRecord{
+				{Type: "NXDOMAIN", TTL: "1h", Regexp: ".*\\.ads\\.com"},
+			},
+		},
+		{
+			name:     "NXDOMAIN without targets",
+			endpoint: endpoint.NewEndpointWithTTL("blocked.example.com", "NXDOMAIN", 3600),
+			expected: []*DNSRecord{
+				{Name: "blocked.example.com", Type: "NXDOMAIN", TTL: "1h"},
+			},
+		},
+		{
+			name:        "One invalid target",
+			endpoint:    endpoint.NewEndpointWithTTL("multi.example.com", "A", 3600, "192.0.2.1", "999.999.999.999"),
+			expectError: true,
+		},
+		{
+			name:        "No targets",
+			endpoint:    endpoint.NewEndpointWithTTL("multi.example.com", "A", 3600),
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := NewDNSRecords(tt.endpoint)
+			if tt.expectError {
+				assert.Error(t, err)
+				assert.Nil(t, records)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, records)
+		})
+	}
+}
+
+func TestRegexpRecordName(t *testing.T) {
+	name := regexpRecordName(".*\\.ads\\.com", "regexp.example.com")
+	assert.Regexp(t, `^[0-9a-f]{16}\.regexp\.example\.com$`, name)
+	assert.NoError(t, validateDomain(name))
+
+	assert.Equal(t, name, regexpRecordName(".*\\.ads\\.com", "Regexp.Example.com."), "suffix should be normalized")
+	assert.NotEqual(t, name, regexpRecordName(".*\\.tracking\\.com", "regexp.example.com"), "different patterns should get different names")
+}
+
+func TestChangedFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		current  *DNSRecord
+		wanted   *DNSRecord
+		expected map[string]string
+	}{
+		{
+			name:     "Identical records",
+			current:  &DNSRecord{ID: "*1", Name: "example.com", Type: "A", Address: "192.0.2.1", TTL: "1h"},
+			wanted:   &DNSRecord{Name: "example.com", Type: "A", Address: "192.0.2.1", TTL: "1h"},
+			expected: map[string]string{},
+		},
+		{
+			name:     "Equivalent values",
+			current:  &DNSRecord{ID: "*1", Name: "example.com", Type: "AAAA", Address: "2001:db8::1", TTL: "1h", Disabled: "false"},
+			wanted:   &DNSRecord{Name: "Example.com", Type: "AAAA", Address: "2001:db8:0:0::1", TTL: "60m"},
+			expected: map[string]string{},
+		},
+		{
+			name:     "Changed fields",
+			current:  &DNSRecord{ID: "*1", Name: "example.com", Type: "A", Address: "192.0.2.1", TTL: "1h", Disabled: "false"},
+			wanted:   &DNSRecord{Name: "example.com", Type: "A", Address: "192.0.2.2", TTL: "5m", Disabled: "true"},
+			expected: map[string]string{"address": "192.0.2.2", "ttl": "5m", "disabled": "true"},
+		},
+		{
+			name:     "Unset fields are cleared",
+			current:  &DNSRecord{ID: "*1", Name: "example.com", Type: "A", Address: "192.0.2.1", Comment: "old", AddressList: "list"},
+			wanted:   &DNSRecord{Name: "example.com", Type: "A", Address: "192.0.2.1"},
+			expected: map[string]string{"comment": "", "address-list": ""},
+		},
+		{
+			name:     "Unset disabled flag is cleared",
+			current:  &DNSRecord{ID: "*1", Name: "example.com", Type: "A", Address: "192.0.2.1", Disabled: "true"},
+			wanted:   &DNSRecord{Name: "example.com", Type: "A", Address: "192.0.2.1"},
+			expected: map[string]string{"disabled": "false"},
+		},
+		{
+			name:     "Unset match-subdomain flag is cleared",
+			current:  &DNSRecord{ID: "*1", Name: "example.com", Type: "A", Address: "192.0.2.1", MatchSubdomain: "true"},
+			wanted:   &DNSRecord{Name: "example.com", Type: "A", Address: "192.0.2.1"},
+			expected: map[string]string{"match-subdomain": "false"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := changedFields(tt.current, tt.wanted)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, fields)
+		})
+	}
+}
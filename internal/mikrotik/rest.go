@@ -0,0 +1,195 @@
+// Rest API Docs: https://help.mikrotik.com/docs/display/ROS/REST+API
+
+package mikrotik
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/publicsuffix"
+)
+
+// requestError is returned when the API responds with a non-2xx status code.
+// RouterOS describes the failure in a JSON body, i.e. {"error":400,"message":"Bad Request","detail":"failure: entry
+// already exists"}, whose message and detail are kept when present.
+type requestError struct {
+	StatusCode int    `json:"error"`
+	Status     string `json:"-"`
+	Message    string `json:"message"`
+	Detail     string `json:"detail"`
+}
+
+func (e *requestError) Error() string {
+	if e.Detail != "" {
+		return fmt.Sprintf("request failed: %s: %s", e.Status, e.Detail)
+	}
+	return fmt.Sprintf("request failed: %s", e.Status)
+}
+
+// Unwrap returns the sentinel error matching the detail of the failure, if it is a known one
+func (e *requestError) Unwrap() error {
+	return routerOSError(e.Detail)
+}
+
+// newRequestError creates the error for a response with a non-2xx status code, decoding the RouterOS error in its body.
+// Bodies that are not RouterOS errors, i.e. from a proxy in front of the router, are ignored.
+func newRequestError(resp *http.Response, body []byte) *requestError {
+	reqErr := &requestError{}
+	if err := json.Unmarshal(body, reqErr); err != nil {
+		reqErr = &requestError{}
+	}
+	reqErr.StatusCode = resp.StatusCode
+	reqErr.Status = resp.Status
+	return reqErr
+}
+
+// restTransport talks to a router through the REST API under /rest/
+type restTransport struct {
+	baseUrl  string
+	username string
+	password string
+	client   *http.Client
+}
+
+// newRestTransport creates a transport for the REST API at the given base URL
+func newRestTransport(baseUrl string, config *MikrotikConnectionConfig) (*restTransport, error) {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		log.Errorf("failed to create cookie jar: %v", err)
+		return nil, err
+	}
+
+	return &restTransport{
+		baseUrl:  baseUrl,
+		username: config.Username,
+		password: config.Password,
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext:         (&net.Dialer{Timeout: config.ConnectTimeout}).DialContext,
+				TLSHandshakeTimeout: config.ConnectTimeout,
+				TLSClientConfig: &tls.Config{
+					InsecureSkipVerify: config.SkipTLSVerify,
+				},
+			},
+			Jar: jar,
+		},
+	}, nil
+}
+
+func (t *restTransport) get(ctx context.Context, path string, query url.Values, out any) error {
+	// Multiple values of a query parameter are sent as a comma-separated list, which the API matches as any of them
+	if len(query) > 0 {
+		params := url.Values{}
+		for key, values := range query {
+			params.Set(key, strings.Join(values, ","))
+		}
+		path = fmt.Sprintf("%s?%s", path, params.Encode())
+	}
+
+	resp, err := t.doRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+		log.Errorf("error decoding response body: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (t *restTransport) add(ctx context.Context, path string, item any, out any) error {
+	jsonBody, err := json.Marshal(item)
+	if err != nil {
+		log.Errorf("error marshalling item: %v", err)
+		return err
+	}
+
+	resp, err := t.doRequest(ctx, http.MethodPut, path, jsonBody)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+		log.Errorf("error decoding response body: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (t *restTransport) set(ctx context.Context, path, id string, fields any, out any) error {
+	jsonBody, err := json.Marshal(fields)
+	if err != nil {
+		log.Errorf("error marshalling fields: %v", err)
+		return err
+	}
+
+	resp, err := t.doRequest(ctx, http.MethodPatch, fmt.Sprintf("%s/%s", path, id), jsonBody)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+		log.Errorf("error decoding response body: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (t *restTransport) remove(ctx context.Context, path, id string) error {
+	resp, err := t.doRequest(ctx, http.MethodDelete, fmt.Sprintf("%s/%s", path, id), nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// doRequest sends an HTTP request to the MikroTik API with credentials
+func (t *restTransport) doRequest(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	endpoint_url := fmt.Sprintf("%s/rest/%s", t.baseUrl, path)
+	log.Debugf("sending %s request to: %s", method, endpoint_url)
+
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint_url, bodyReader)
+	if err != nil {
+		log.Errorf("failed to create HTTP request: %v", err)
+		return nil, err
+	}
+
+	req.SetBasicAuth(t.username, t.password)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		log.Errorf("error sending HTTP request: %v", err)
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		log.Errorf("request failed with status %s, response: %s", resp.Status, string(respBody))
+		return nil, newRequestError(resp, respBody)
+	}
+	log.Debugf("request succeeded with status %s", resp.Status)
+	reportResponseStatus(ctx, resp.StatusCode)
+
+	return resp, nil
+}
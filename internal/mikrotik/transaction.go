@@ -0,0 +1,124 @@
+package mikrotik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// TransactionError is returned when changes could not be applied on a router.
+// It carries the full outcome of the transaction: what had been changed before the failure, and what could not be
+// undone while rolling it back.
+type TransactionError struct {
+	// Err is the failure that aborted the transaction
+	Err error
+
+	// Deleted, Updated and Created are the records changed on the router before the failure.
+	// Updated records are listed as they were before the update.
+	Deleted []*DNSRecord
+	Updated []*DNSRecord
+	Created []*DNSRecord
+
+	// Restored, Reverted and Removed are the records recreated, changed back and deleted again while rolling back
+	Restored []*DNSRecord
+	Reverted []*DNSRecord
+	Removed  []*DNSRecord
+
+	// RollbackErrs are the failures that occurred while rolling back. If empty, the router was left as it was.
+	RollbackErrs []error
+}
+
+func (e *TransactionError) Error() string {
+	outcome := fmt.Sprintf("rolled back %d deleted, %d updated and %d created records", len(e.Restored), len(e.Reverted), len(e.Removed))
+	if len(e.RollbackErrs) > 0 {
+		outcome = fmt.Sprintf("rollback incomplete, %d of %d deleted records restored, %d of %d updated records reverted and %d of %d created records removed: %v",
+			len(e.Restored), len(e.Deleted), len(e.Reverted), len(e.Updated), len(e.Removed), len(e.Created), errors.Join(e.RollbackErrs...))
+	}
+	return fmt.Sprintf("applying changes failed: %v (%s)", e.Err, strings.ReplaceAll(outcome, "\n", "; "))
+}
+
+func (e *TransactionError) Unwrap() []error {
+	return append([]error{e.Err}, e.RollbackErrs...)
+}
+
+// RolledBack checks if the router was left as it was before the transaction
+func (e *TransactionError) RolledBack() bool {
+	return len(e.RollbackErrs) == 0
+}
+
+// transaction keeps track of the records changed on a router, so that they can be undone if a later step fails
+type transaction struct {
+	client  *MikrotikApiClient
+	deleted []*DNSRecord
+	updated []recordUpdate
+	created []*DNSRecord
+}
+
+// recordUpdate is a record updated in place, as it was before and after the update
+type recordUpdate struct {
+	before *DNSRecord
+	after  *DNSRecord
+}
+
+// rollback undoes the changes of the transaction after it failed with the given error.
+// The rollback is not aborted when ctx is cancelled, as that would leave the router half-changed; the request timeout
+// still applies to each of its requests.
+// Changes are undone in the reverse order they were made in, so that restoring the deleted records cannot conflict
+// with the created ones.
+func (tx *transaction) rollback(ctx context.Context, err error) *TransactionError {
+	ctx = context.WithoutCancel(ctx)
+
+	txErr := &TransactionError{Err: err, Deleted: tx.deleted, Created: tx.created}
+	for _, update := range tx.updated {
+		txErr.Updated = append(txErr.Updated, update.before)
+	}
+	if len(tx.deleted) == 0 && len(tx.updated) == 0 && len(tx.created) == 0 {
+		return txErr
+	}
+	if tx.client.dryRun != nil {
+		// Nothing was changed, so there is nothing to undo either
+		return txErr
+	}
+
+	log.Warnf("Rolling back %d deleted, %d updated and %d created records on %s: %v", len(tx.deleted), len(tx.updated), len(tx.created), tx.client.RouterName(), err)
+
+	for i := len(tx.created) - 1; i >= 0; i-- {
+		record := tx.created[i]
+		if err := tx.client.RemoveDNSRecord(ctx, record); err != nil {
+			txErr.RollbackErrs = append(txErr.RollbackErrs, fmt.Errorf("removing created record %s failed: %w", record.ID, err))
+			continue
+		}
+		txErr.Removed = append(txErr.Removed, record)
+	}
+
+	for i := len(tx.updated) - 1; i >= 0; i-- {
+		update := tx.updated[i]
+		reverted, err := tx.client.PatchDNSRecord(ctx, update.after, update.before)
+		if err != nil {
+			txErr.RollbackErrs = append(txErr.RollbackErrs, fmt.Errorf("reverting updated record %s failed: %w", update.before.ID, err))
+			continue
+		}
+		txErr.Reverted = append(txErr.Reverted, reverted)
+	}
+
+	for i := len(tx.deleted) - 1; i >= 0; i-- {
+		record := tx.deleted[i]
+		restored, err := tx.client.RestoreDNSRecord(ctx, record)
+		if err != nil {
+			txErr.RollbackErrs = append(txErr.RollbackErrs, fmt.Errorf("restoring deleted record %s failed: %w", record.ID, err))
+			continue
+		}
+		txErr.Restored = append(txErr.Restored, restored)
+	}
+
+	if txErr.RolledBack() {
+		log.Infof("Rolled back changes on %s", tx.client.RouterName())
+	} else {
+		log.Errorf("Rollback on %s is incomplete: %v", tx.client.RouterName(), errors.Join(txErr.RollbackErrs...))
+	}
+
+	return txErr
+}
@@ -0,0 +1,133 @@
+package mikrotik
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// migrateTXTRegistry folds the TXT registry entries created by external-dns on a router into the comment of the static
+// entries they own, and deletes them. Labels already stored in the comment of an entry take precedence over the ones of
+// its TXT registry entry. TXT registry entries that do not own any static entry are left as they are.
+func (p *MikrotikProvider) migrateTXTRegistry(ctx context.Context, client *MikrotikApiClient) error {
+	records, err := client.GetAllDNSRecords(ctx)
+	if err != nil {
+		return err
+	}
+
+	migrated := 0
+	for _, registry := range records {
+		if defaultValue(registry.Type, "A") != "TXT" || registry.Name == "" {
+			continue
+		}
+		labels, err := endpoint.NewLabelsFromStringPlain(registry.Text)
+		if err != nil {
+			continue
+		}
+		if p.config.TXTOwnerID != "" && labels[endpoint.OwnerLabelKey] != p.config.TXTOwnerID {
+			log.Debugf("Skipping TXT registry entry of another owner: %+v", registry)
+			continue
+		}
+
+		owned := p.registryOwnedRecords(records, registry.Name)
+		if len(owned) == 0 {
+			log.Debugf("Skipping TXT registry entry without records: %+v", registry)
+			continue
+		}
+
+		for _, record := range owned {
+			comment, current, err := decodeLabels(client.unmarkOwned(&record).Comment)
+			if err != nil {
+				return err
+			}
+			merged := endpoint.NewLabels()
+			for key, value := range labels {
+				merged[key] = value
+			}
+			for key, value := range current {
+				merged[key] = value
+			}
+
+			wanted := record
+			wanted.Comment = encodeLabels(comment, merged)
+			log.Infof("Moving the labels of TXT registry entry %s to the comment of record %s: %s", registry.ID, record.ID, wanted.Comment)
+			if _, err := client.PatchDNSRecord(ctx, &record, &wanted); err != nil {
+				return fmt.Errorf("moving the labels of TXT registry entry %s to record %s failed: %w", registry.ID, record.ID, err)
+			}
+		}
+
+		log.Infof("Deleting migrated TXT registry entry %s: %s", registry.ID, registry.Name)
+		if err := client.removeDNSRecord(ctx, &registry); err != nil {
+			return fmt.Errorf("deleting TXT registry entry %s failed: %w", registry.ID, err)
+		}
+		migrated++
+	}
+
+	log.Infof("Migrated %d TXT registry entries on %s", migrated, client.RouterName())
+	return nil
+}
+
+// registryOwnedRecords returns the static entries owned by the TXT registry entry with the given name.
+// The name is first read in the current format of the registry, which includes the type of the owned record
+// (i.e. a-web.example.com), then in the legacy format, which is the name of the owned records of any type.
+func (p *MikrotikProvider) registryOwnedRecords(records []DNSRecord, registryName string) []DNSRecord {
+	name := strings.TrimPrefix(registryName, p.config.TXTPrefix)
+	if p.config.TXTSuffix != "" {
+		first, rest, _ := strings.Cut(name, ".")
+		name = strings.TrimSuffix(first, p.config.TXTSuffix)
+		if rest != "" {
+			name += "." + rest
+		}
+	}
+
+	if recordType, ownedName, ok := strings.Cut(name, "-"); ok {
+		if owned := matchRecords(records, ownedName, strings.ToUpper(recordType)); len(owned) > 0 {
+			return owned
+		}
+	}
+	return matchRecords(records, name, "")
+}
+
+// matchRecords returns the static entries with the given name and type, or of any type other than TXT if the type is empty
+func matchRecords(records []DNSRecord, name, recordType string) []DNSRecord {
+	var matches []DNSRecord
+	for _, record := range records {
+		current := defaultValue(record.Type, "A")
+		if !strings.EqualFold(record.Name, name) || (recordType == "" && current == "TXT") || (recordType != "" && current != recordType) {
+			continue
+		}
+		matches = append(matches, record)
+	}
+	return matches
+}
+
+// txtMigrationEnabled checks if the TXT registry entries are folded into comments before the records are first read.
+// The migration is not run in dry-run mode, as its changes would be reported along with the ones of the next sync.
+func (p *MikrotikProvider) txtMigrationEnabled() bool {
+	return p.commentLabelsEnabled() && p.config.MigrateTXTRegistry && !p.dryRunEnabled()
+}
+
+// migrateRouter runs the migration of the TXT registry on a router, unless it already succeeded
+func (p *MikrotikProvider) migrateRouter(ctx context.Context, client *MikrotikApiClient) error {
+	p.mu.Lock()
+	done := p.migrated[client.RouterName()]
+	p.mu.Unlock()
+	if done {
+		return nil
+	}
+
+	if err := p.migrateTXTRegistry(ctx, client); err != nil {
+		return fmt.Errorf("migrating the TXT registry failed: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.migrated == nil {
+		p.migrated = map[string]bool{}
+	}
+	p.migrated[client.RouterName()] = true
+	return nil
+}
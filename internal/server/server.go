@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -26,11 +27,30 @@ func HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func ReadinessHandler(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	_, err := w.Write([]byte("OK"))
-	if err != nil {
-		log.Errorf("error writing response: %v", err)
+// readinessResponse is the body returned by the readiness probe
+type readinessResponse struct {
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Details any    `json:"details,omitempty"`
+}
+
+// ReadinessHandler reports whether the provider is able to manage records, along with the state it describes
+func ReadinessHandler(p *webhook.Webhook) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response := readinessResponse{Status: "ready", Details: p.Status()}
+		status := http.StatusOK
+		if err := p.Ready(); err != nil {
+			log.Warnf("not ready: %v", err)
+			response.Status = "not ready"
+			response.Error = err.Error()
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Errorf("error writing response: %v", err)
+		}
 	}
 }
 
@@ -52,7 +72,8 @@ func Init(config configuration.Config, p *webhook.Webhook) (*http.Server, *http.
 	healthRouter := chi.NewRouter()
 	healthRouter.Get("/metrics", promhttp.Handler().ServeHTTP)
 	healthRouter.Get("/healthz", HealthCheckHandler)
-	healthRouter.Get("/readyz", ReadinessHandler)
+	healthRouter.Get("/readyz", ReadinessHandler(p))
+	healthRouter.Get("/dryrun", p.DryRunPlan)
 
 	healthServer := createHTTPServer("0.0.0.0:8080", healthRouter, config.ServerReadTimeout, config.ServerWriteTimeout)
 	go func() {
@@ -2,6 +2,7 @@ package webhook
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -27,6 +28,53 @@ type Webhook struct {
 	provider provider.Provider
 }
 
+// ReadinessChecker is implemented by providers that can tell whether they are able to serve requests
+type ReadinessChecker interface {
+	Ready() error
+}
+
+// StatusReporter is implemented by providers that can describe their state, i.e. for the readiness probe
+type StatusReporter interface {
+	Status() any
+}
+
+// ConnectionChecker is implemented by providers that can start before their backend is reachable.
+// Records are neither served nor changed until they are connected.
+type ConnectionChecker interface {
+	Connected() error
+}
+
+// DryRunReporter is implemented by providers that can report the changes they would have applied in dry-run mode
+type DryRunReporter interface {
+	// DryRunPlan returns the changes the last apply would have made, or nil when dry-run mode is disabled
+	DryRunPlan() any
+}
+
+// ClassifiedError is implemented by provider errors that tell why a request failed and which endpoints caused it.
+// They are reported with a matching HTTP status instead of a plain 500.
+type ClassifiedError interface {
+	error
+	// ErrorKind returns why the request failed, i.e. "validation", "not-found", "conflict", "auth" or "transport"
+	ErrorKind() string
+	// Endpoints returns the endpoints that caused the failure, if known
+	Endpoints() []*endpoint.Endpoint
+}
+
+// errorKindStatuses maps the kinds of ClassifiedError to the status they are reported with. Other kinds, including the
+// failures caused by single endpoints (i.e. "validation", "not-found" and "conflict"), are reported as 500: external-dns
+// only retries requests that failed with a 5xx status, and exits on any other one.
+var errorKindStatuses = map[string]int{
+	"auth":      http.StatusBadGateway,
+	"transport": http.StatusServiceUnavailable,
+}
+
+// errorResponse is the body of the responses to requests that failed with a ClassifiedError
+type errorResponse struct {
+	Error     string               `json:"error"`
+	Kind      string               `json:"kind,omitempty"`
+	Endpoints []*endpoint.Endpoint `json:"endpoints,omitempty"`
+}
+
 // New creates a new instance of the Webhook
 func New(provider provider.Provider) *Webhook {
 	p := Webhook{provider: provider}
@@ -91,6 +139,25 @@ func (p *Webhook) headerCheck(isContentType bool, w http.ResponseWriter, r *http
 	return nil
 }
 
+// connectedCheck responds with a 503 while the provider is not connected to its backend
+func (p *Webhook) connectedCheck(w http.ResponseWriter, r *http.Request) error {
+	checker, ok := p.provider.(ConnectionChecker)
+	if !ok {
+		return nil
+	}
+	err := checker.Connected()
+	if err == nil {
+		return nil
+	}
+
+	w.Header().Set(contentTypeHeader, contentTypePlaintext)
+	w.WriteHeader(http.StatusServiceUnavailable)
+	if _, writeErr := fmt.Fprintf(w, "provider is not connected: %s", err.Error()); writeErr != nil {
+		requestLog(r).WithField(logFieldError, writeErr).Error("error writing error message to response writer")
+	}
+	return err
+}
+
 // Records handles the get request for records
 func (p *Webhook) Records(w http.ResponseWriter, r *http.Request) {
 	if err := p.acceptHeaderCheck(w, r); err != nil {
@@ -98,12 +165,17 @@ func (p *Webhook) Records(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := p.connectedCheck(w, r); err != nil {
+		requestLog(r).WithField(logFieldError, err).Error("provider is not connected")
+		return
+	}
+
 	requestLog(r).Debug("requesting records")
 	ctx := r.Context()
 	records, err := p.provider.Records(ctx)
 	if err != nil {
 		requestLog(r).WithField(logFieldError, err).Error("error getting records")
-		w.WriteHeader(http.StatusInternalServerError)
+		writeProviderError(w, r, err)
 		return
 	}
 
@@ -125,6 +197,11 @@ func (p *Webhook) ApplyChanges(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := p.connectedCheck(w, r); err != nil {
+		requestLog(r).WithField(logFieldError, err).Error("provider is not connected")
+		return
+	}
+
 	var changes plan.Changes
 	ctx := r.Context()
 	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
@@ -142,11 +219,34 @@ func (p *Webhook) ApplyChanges(w http.ResponseWriter, r *http.Request) {
 	requestLog(r).Debugf("requesting apply changes, create: %d , updateOld: %d, updateNew: %d, delete: %d",
 		len(changes.Create), len(changes.UpdateOld), len(changes.UpdateNew), len(changes.Delete))
 	if err := p.provider.ApplyChanges(ctx, &changes); err != nil {
+		requestLog(r).WithField(logFieldError, err).Error("error applying changes")
+		writeProviderError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeProviderError responds to a request that failed in the provider. A ClassifiedError is reported with the status of
+// its kind and a JSON body describing it, other errors are reported as a plain 500.
+func writeProviderError(w http.ResponseWriter, r *http.Request, err error) {
+	var classified ClassifiedError
+	if !errors.As(err, &classified) {
 		w.Header().Set(contentTypeHeader, contentTypePlaintext)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	w.WriteHeader(http.StatusNoContent)
+
+	status, ok := errorKindStatuses[classified.ErrorKind()]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	w.Header().Set(contentTypeHeader, "application/json")
+	w.WriteHeader(status)
+	response := errorResponse{Error: classified.Error(), Kind: classified.ErrorKind(), Endpoints: classified.Endpoints()}
+	if writeErr := json.NewEncoder(w).Encode(response); writeErr != nil {
+		requestLog(r).WithField(logFieldError, writeErr).Error("error writing error message to response writer")
+	}
 }
 
 // AdjustEndpoints handles the post request for adjusting endpoints
@@ -211,6 +311,43 @@ func (p *Webhook) Negotiate(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// DryRunPlan handles the get request for the changes the provider would have applied in dry-run mode
+func (p *Webhook) DryRunPlan(w http.ResponseWriter, r *http.Request) {
+	var dryRun any
+	if reporter, ok := p.provider.(DryRunReporter); ok {
+		dryRun = reporter.DryRunPlan()
+	}
+	if dryRun == nil {
+		w.Header().Set(contentTypeHeader, contentTypePlaintext)
+		w.WriteHeader(http.StatusNotFound)
+		if _, writeErr := fmt.Fprint(w, "dry-run mode is not enabled"); writeErr != nil {
+			requestLog(r).WithField(logFieldError, writeErr).Error("error writing error message to response writer")
+		}
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, "application/json")
+	if err := json.NewEncoder(w).Encode(dryRun); err != nil {
+		requestLog(r).WithField(logFieldError, err).Error("error encoding dry-run plan")
+	}
+}
+
+// Ready checks if the provider is able to serve requests. Providers that do not implement ReadinessChecker are always ready.
+func (p *Webhook) Ready() error {
+	if checker, ok := p.provider.(ReadinessChecker); ok {
+		return checker.Ready()
+	}
+	return nil
+}
+
+// Status describes the state of the provider. It is nil for providers that do not implement StatusReporter.
+func (p *Webhook) Status() any {
+	if reporter, ok := p.provider.(StatusReporter); ok {
+		return reporter.Status()
+	}
+	return nil
+}
+
 func requestLog(r *http.Request) *log.Entry {
 	return log.WithFields(log.Fields{logFieldRequestMethod: r.Method, logFieldRequestPath: r.URL.Path})
 }
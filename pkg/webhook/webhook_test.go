@@ -0,0 +1,104 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+	"sigs.k8s.io/external-dns/provider"
+)
+
+// classifiedError is a ClassifiedError of the given kind
+type classifiedError struct {
+	kind      string
+	endpoints []*endpoint.Endpoint
+}
+
+func (e *classifiedError) Error() string {
+	return e.kind + " failure"
+}
+
+func (e *classifiedError) ErrorKind() string {
+	return e.kind
+}
+
+func (e *classifiedError) Endpoints() []*endpoint.Endpoint {
+	return e.endpoints
+}
+
+// failingProvider fails all requests with the given error
+type failingProvider struct {
+	provider.BaseProvider
+	err error
+}
+
+func (p *failingProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	return nil, p.err
+}
+
+func (p *failingProvider) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
+	return p.err
+}
+
+func TestProviderErrorStatus(t *testing.T) {
+	invalid := endpoint.NewEndpoint("a.example.com", "A", "not-an-ip")
+
+	testCases := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedKind   string
+	}{
+		{name: "Validation", err: &classifiedError{kind: "validation", endpoints: []*endpoint.Endpoint{invalid}}, expectedStatus: http.StatusInternalServerError, expectedKind: "validation"},
+		{name: "Not found", err: &classifiedError{kind: "not-found", endpoints: []*endpoint.Endpoint{invalid}}, expectedStatus: http.StatusInternalServerError, expectedKind: "not-found"},
+		{name: "Conflict", err: &classifiedError{kind: "conflict", endpoints: []*endpoint.Endpoint{invalid}}, expectedStatus: http.StatusInternalServerError, expectedKind: "conflict"},
+		{name: "Auth", err: &classifiedError{kind: "auth"}, expectedStatus: http.StatusBadGateway, expectedKind: "auth"},
+		{name: "Transport", err: &classifiedError{kind: "transport"}, expectedStatus: http.StatusServiceUnavailable, expectedKind: "transport"},
+		{name: "Unknown kind", err: &classifiedError{kind: ""}, expectedStatus: http.StatusInternalServerError},
+		{name: "Unclassified", err: errors.New("boom"), expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			webhook := New(&failingProvider{err: tc.err})
+
+			requests := map[string]*http.Request{
+				"records": httptest.NewRequest(http.MethodGet, "/records", nil),
+				"apply":   httptest.NewRequest(http.MethodPost, "/records", strings.NewReader(`{}`)),
+			}
+			requests["records"].Header.Set(acceptHeader, string(mediaTypeVersion1))
+			requests["apply"].Header.Set(contentTypeHeader, string(mediaTypeVersion1))
+
+			for name, r := range requests {
+				w := httptest.NewRecorder()
+				if name == "records" {
+					webhook.Records(w, r)
+				} else {
+					webhook.ApplyChanges(w, r)
+				}
+
+				if w.Code != tc.expectedStatus {
+					t.Errorf("Expected %s to respond with status %d, got %d", name, tc.expectedStatus, w.Code)
+				}
+
+				var classified ClassifiedError
+				if !errors.As(tc.err, &classified) {
+					continue
+				}
+				var response errorResponse
+				if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+					t.Fatalf("Expected %s to respond with a JSON body, got %v", name, err)
+				}
+				if response.Kind != tc.expectedKind || response.Error != tc.err.Error() || len(response.Endpoints) != len(classified.Endpoints()) {
+					t.Errorf("Expected %s to respond with kind %q and the endpoints of the error, got %+v", name, tc.expectedKind, response)
+				}
+			}
+		})
+	}
+}